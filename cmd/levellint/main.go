@@ -0,0 +1,96 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Command levellint checks levels for mistakes and exits with a non-zero status if any
+// are found. Without file arguments it checks every level in the maps directory of the
+// assets, which default to the data directory.
+//
+//	levellint data/maps/level1.json
+//	levellint -assets mymod.zip
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+)
+
+var assetsFlag = flag.String("assets", "data", "asset directories or zip archives, separated by "+string(os.PathListSeparator))
+
+func main() {
+	flag.Parse()
+
+	sp, err := world.OpenSearchPath(filepath.SplitList(*assetsFlag))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	world.SetAssets(sp)
+	defer world.SetAssets(nil)
+
+	failed := false
+	check := func(file string, lvl *world.Level, err error) {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			return
+		}
+
+		for _, p := range world.Validate(lvl) {
+			fmt.Printf("%s: %v\n", file, p)
+			failed = true
+		}
+	}
+
+	if flag.NArg() > 0 {
+		for _, file := range flag.Args() {
+			lvl, err := world.LoadLevelFile(file)
+			check(file, lvl, err)
+		}
+	} else {
+		found := 0
+		for _, pattern := range []string{"maps/*.json", "maps/*.tmx"} {
+			files, err := fs.Glob(world.Assets(), pattern)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
+			}
+			for _, file := range files {
+				lvl, err := world.LoadLevel(strings.TrimSuffix(path.Base(file), path.Ext(file)))
+				check(file, lvl, err)
+				found++
+			}
+		}
+
+		// Checking nothing is most likely a wrong -assets path.
+		if found == 0 {
+			fmt.Fprintln(os.Stderr, "no levels found in", *assetsFlag)
+			failed = true
+		}
+	}
+
+	if failed {
+		world.SetAssets(nil)
+		os.Exit(1)
+	}
+}
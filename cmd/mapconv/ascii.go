@@ -0,0 +1,385 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+)
+
+// The ASCII format is line based. Keys end with a colon, and sections hold lines indented
+// by two spaces. Lines starting with ';' are comments.
+//
+//	; go-wolf ascii map
+//	name: Level 1
+//	sky: 75 75 75
+//	start: 22 11.5 180
+//	exit: 1 2
+//	legend:
+//	  . = tile 0
+//	  4 = tile 4
+//	  A = sprite pillar.png
+//	tiles:
+//	  4444
+//	  4.A4
+//	  4444
+//	entities:
+//	  1.25 1.5 barrel.png
+//
+// The start angle is in degrees. Sprite characters put a sprite in the middle of an empty
+// cell, other sprites are listed under entities. Sprites in the grid come first, row by row,
+// followed by the listed ones.
+
+const (
+	asciiHeader = "; go-wolf ascii map"
+	asciiIndent = "  "
+
+	// Tile N uses character N in tileChars, if there is one.
+	tileChars   = ".123456789abcdefghijklmnopqrstuvwxyz"
+	extraChars  = "#%&*+-=?^~$!<>|/_"
+	spriteChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+type asciiLegend struct {
+	tiles   map[int]byte
+	sprites map[string]byte
+}
+
+func newASCIILegend(lvl *world.Level) (*asciiLegend, error) {
+	l := &asciiLegend{tiles: make(map[int]byte), sprites: make(map[string]byte)}
+
+	var tiles []int
+	seen := make(map[int]bool)
+	for _, layer := range [][][]int{lvl.Tiles, lvl.Floor, lvl.Ceiling} {
+		for _, row := range layer {
+			for _, t := range row {
+				if !seen[t] {
+					seen[t] = true
+					tiles = append(tiles, t)
+				}
+			}
+		}
+	}
+	sort.Ints(tiles)
+
+	extra := extraChars
+	for _, t := range tiles {
+		if t >= 0 && t < len(tileChars) {
+			l.tiles[t] = tileChars[t]
+		} else if len(extra) > 0 {
+			l.tiles[t] = extra[0]
+			extra = extra[1:]
+		} else {
+			return nil, fmt.Errorf("too many different tiles")
+		}
+	}
+
+	var sprites []string
+	for _, e := range lvl.Entities {
+		if _, ok := l.sprites[e.Sprite]; !ok {
+			l.sprites[e.Sprite] = 0
+			sprites = append(sprites, e.Sprite)
+		}
+	}
+	sort.Strings(sprites)
+
+	for i, s := range sprites {
+		if i < len(spriteChars) {
+			l.sprites[s] = spriteChars[i]
+		} else {
+			delete(l.sprites, s)
+		}
+	}
+	return l, nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func writeASCII(w io.Writer, lvl *world.Level) error {
+	legend, err := newASCIILegend(lvl)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	header := func(key, value string) {
+		fmt.Fprintln(bw, strings.TrimSpace(key+": "+value))
+	}
+
+	fmt.Fprintln(bw, asciiHeader)
+	header("version", strconv.Itoa(lvl.Version))
+	header("name", lvl.Name)
+	header("music", lvl.Music)
+	header("sky", fmt.Sprintf("%d %d %d", lvl.Sky.Color[0], lvl.Sky.Color[1], lvl.Sky.Color[2]))
+	header("skytexture", lvl.Sky.Texture)
+
+	degrees := lvl.Start.Angle * 180 / math.Pi
+	if r := math.Round(degrees); math.Abs(r-degrees) < 1e-9 {
+		degrees = r
+	}
+	header("start", strings.Join([]string{formatFloat(lvl.Start.Pos[0]), formatFloat(lvl.Start.Pos[1]), formatFloat(degrees)}, " "))
+
+	if e := lvl.Exit; e != nil {
+		header("exit", fmt.Sprintf("%d %d", e[0], e[1]))
+	}
+
+	fmt.Fprintln(bw, "legend:")
+	var tiles []int
+	for t := range legend.tiles {
+		tiles = append(tiles, t)
+	}
+	sort.Ints(tiles)
+	for _, t := range tiles {
+		fmt.Fprintf(bw, "%s%c = tile %d\n", asciiIndent, legend.tiles[t], t)
+	}
+
+	var sprites []string
+	for s := range legend.sprites {
+		sprites = append(sprites, s)
+	}
+	sort.Strings(sprites)
+	for _, s := range sprites {
+		fmt.Fprintf(bw, "%s%c = sprite %s\n", asciiIndent, legend.sprites[s], s)
+	}
+
+	// Sprites in the middle of an empty cell are drawn in the tile grid.
+	grid := make([][]byte, len(lvl.Tiles))
+	for x, row := range lvl.Tiles {
+		grid[x] = make([]byte, len(row))
+		for y, t := range row {
+			grid[x][y] = legend.tiles[t]
+		}
+	}
+
+	// The grid is read row by row before the listed entities, so to keep the order of
+	// the entities only a leading run of them in grid order can be drawn.
+	var listed []world.Entity
+	lastX, lastY := -1, -1
+	for i, e := range lvl.Entities {
+		c, ok := legend.sprites[e.Sprite]
+		x, y := int(math.Floor(e.Pos[0])), int(math.Floor(e.Pos[1]))
+
+		if ok && e.Pos[0] == float64(x)+0.5 && e.Pos[1] == float64(y)+0.5 &&
+			x >= 0 && x < len(grid) && y >= 0 && y < len(grid[x]) && lvl.Tiles[x][y] == 0 && grid[x][y] == legend.tiles[0] &&
+			(x > lastX || (x == lastX && y > lastY)) {
+			grid[x][y] = c
+			lastX, lastY = x, y
+		} else {
+			listed = lvl.Entities[i:]
+			break
+		}
+	}
+
+	fmt.Fprintln(bw, "tiles:")
+	for _, row := range grid {
+		fmt.Fprintf(bw, "%s%s\n", asciiIndent, row)
+	}
+
+	for _, layer := range []struct {
+		name  string
+		tiles [][]int
+	}{{"floor", lvl.Floor}, {"ceiling", lvl.Ceiling}} {
+		fmt.Fprintf(bw, "%s:\n", layer.name)
+		for _, row := range layer.tiles {
+			line := make([]byte, len(row))
+			for y, t := range row {
+				line[y] = legend.tiles[t]
+			}
+			fmt.Fprintf(bw, "%s%s\n", asciiIndent, line)
+		}
+	}
+
+	fmt.Fprintln(bw, "entities:")
+	for _, e := range listed {
+		fmt.Fprintf(bw, "%s%s %s %s\n", asciiIndent, formatFloat(e.Pos[0]), formatFloat(e.Pos[1]), e.Sprite)
+	}
+	return bw.Flush()
+}
+
+func readASCII(r io.Reader) (*world.Level, error) {
+	var (
+		lvl     = &world.Level{Version: world.LevelVersion}
+		tiles   = make(map[byte]int)
+		sprites = make(map[byte]string)
+		section string
+		lineNum int
+		grids   = make(map[string][]string)
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		fail := func(format string, a ...interface{}) error {
+			return fmt.Errorf("line %d: %s", lineNum, fmt.Sprintf(format, a...))
+		}
+
+		if strings.HasPrefix(strings.TrimSpace(line), ";") || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, asciiIndent) {
+			content := line[len(asciiIndent):]
+			switch section {
+			case "legend":
+				var (
+					c          byte
+					kind, args string
+				)
+				fields := strings.SplitN(content, " ", 4)
+				if len(fields) != 4 || len(fields[0]) != 1 || fields[1] != "=" {
+					return nil, fail("invalid legend entry: %q", content)
+				}
+				c, kind, args = fields[0][0], fields[2], fields[3]
+
+				switch kind {
+				case "tile":
+					t, err := strconv.Atoi(args)
+					if err != nil {
+						return nil, fail("invalid tile: %s", args)
+					}
+					tiles[c] = t
+				case "sprite":
+					sprites[c] = args
+				default:
+					return nil, fail("unknown legend kind: %s", kind)
+				}
+			case "tiles", "floor", "ceiling":
+				grids[section] = append(grids[section], content)
+			case "entities":
+				fields := strings.SplitN(content, " ", 3)
+				if len(fields) != 3 {
+					return nil, fail("invalid entity: %q", content)
+				}
+
+				var e world.Entity
+				for i := range e.Pos {
+					v, err := strconv.ParseFloat(fields[i], 64)
+					if err != nil {
+						return nil, fail("invalid entity position: %s", fields[i])
+					}
+					e.Pos[i] = v
+				}
+				e.Sprite = fields[2]
+				lvl.Entities = append(lvl.Entities, e)
+			default:
+				return nil, fail("unexpected indented line")
+			}
+			continue
+		}
+
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return nil, fail("expected a key")
+		}
+
+		key, value := line[:i], strings.TrimSpace(line[i+1:])
+		section = ""
+
+		switch key {
+		case "version":
+			v, err := strconv.Atoi(value)
+			if err != nil || v < 1 || v > world.LevelVersion {
+				return nil, fail("unsupported version: %s", value)
+			}
+			lvl.Version = v
+		case "name":
+			lvl.Name = value
+		case "music":
+			lvl.Music = value
+		case "skytexture":
+			lvl.Sky.Texture = value
+		case "sky":
+			c := &lvl.Sky.Color
+			if _, err := fmt.Sscan(value, &c[0], &c[1], &c[2]); err != nil {
+				return nil, fail("invalid sky color: %s", value)
+			}
+		case "start":
+			var degrees float64
+			s := &lvl.Start
+			if _, err := fmt.Sscan(value, &s.Pos[0], &s.Pos[1], &degrees); err != nil {
+				return nil, fail("invalid start: %s", value)
+			}
+			s.Angle = degrees * math.Pi / 180
+		case "exit":
+			var e [2]int
+			if _, err := fmt.Sscan(value, &e[0], &e[1]); err != nil {
+				return nil, fail("invalid exit: %s", value)
+			}
+			lvl.Exit = &e
+		case "legend", "tiles", "floor", "ceiling", "entities":
+			section = key
+		default:
+			return nil, fail("unknown key: %s", key)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if lvl.Tiles, err = decodeGrid(grids["tiles"], tiles, sprites, lvl); err != nil {
+		return nil, fmt.Errorf("tiles: %v", err)
+	}
+	if lvl.Floor, err = decodeGrid(grids["floor"], tiles, nil, nil); err != nil {
+		return nil, fmt.Errorf("floor: %v", err)
+	}
+	if lvl.Ceiling, err = decodeGrid(grids["ceiling"], tiles, nil, nil); err != nil {
+		return nil, fmt.Errorf("ceiling: %v", err)
+	}
+	return lvl, nil
+}
+
+// decodeGrid turns rows of legend characters into tiles. Sprite characters are
+// added to lvl as entities when sprites is not nil.
+func decodeGrid(rows []string, tiles map[byte]int, sprites map[byte]string, lvl *world.Level) ([][]int, error) {
+	var (
+		layer    [][]int
+		entities []world.Entity
+	)
+
+	for x, row := range rows {
+		line := make([]int, len(row))
+		for y := 0; y < len(row); y++ {
+			c := row[y]
+			if t, ok := tiles[c]; ok {
+				line[y] = t
+			} else if s, ok := sprites[c]; ok {
+				entities = append(entities, world.Entity{Pos: [2]float64{float64(x) + 0.5, float64(y) + 0.5}, Sprite: s})
+			} else {
+				return nil, fmt.Errorf("row %d, column %d: character %q is not in the legend", x, y, c)
+			}
+		}
+		layer = append(layer, line)
+	}
+
+	if lvl != nil {
+		lvl.Entities = append(entities, lvl.Entities...)
+	}
+	return layer, nil
+}
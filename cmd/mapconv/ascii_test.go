@@ -0,0 +1,82 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+)
+
+func asciiRoundTrip(t *testing.T, lvl *world.Level) []byte {
+	var buf bytes.Buffer
+	if err := writeASCII(&buf, lvl); err != nil {
+		t.Fatal(err)
+	}
+
+	back, err := readASCII(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := world.MarshalLevel(back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestASCIIRoundTripLevel1(t *testing.T) {
+	data, err := os.ReadFile("../../data/maps/level1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lvl, err := world.ParseLevel(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := asciiRoundTrip(t, lvl); !bytes.Equal(got, data) {
+		t.Errorf("level changed in the round trip:\n%s", got)
+	}
+}
+
+func TestASCIIRoundTripEntityOrder(t *testing.T) {
+	lvl := &world.Level{
+		Version: world.LevelVersion,
+		Tiles:   [][]int{{1, 1, 1, 1}, {1, 0, 0, 1}, {1, 0, 0, 1}, {1, 1, 1, 1}},
+		Entities: []world.Entity{
+			{Pos: [2]float64{1.5, 1.5}, Sprite: "a.png"},
+			{Pos: [2]float64{2.5, 2.5}, Sprite: "b.png"},
+			{Pos: [2]float64{1.5, 2.5}, Sprite: "a.png"},
+			{Pos: [2]float64{2.25, 1.5}, Sprite: "b.png"},
+			{Pos: [2]float64{2.5, 1.5}, Sprite: "a.png"},
+		},
+	}
+
+	want, err := world.MarshalLevel(lvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := asciiRoundTrip(t, lvl); !bytes.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Command mapconv converts levels between the JSON format in data/maps and
+// an ASCII-art text format that is easy to edit by hand.
+//
+//	mapconv data/maps/level1.json > level1.txt
+//	mapconv -o data/maps/level1.json level1.txt
+//
+// With -chunks, the level is written as a chunked level for large maps, to the maps
+// directory of the asset directory given with -o.
+//
+//	mapconv -chunks 64 -o data huge.txt
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+)
+
+var (
+	outputFlag = flag.String("o", "", "output file, defaults to stdout")
+	chunksFlag = flag.Int("chunks", 0, "write a level with chunks of this size to the asset directory -o")
+)
+
+func main() {
+	log.SetFlags(0)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mapconv [-o output] [-chunks size] <level.json|level.txt>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	input := flag.Arg(0)
+	data, err := os.ReadFile(input)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var lvl *world.Level
+	switch strings.ToLower(filepath.Ext(input)) {
+	case ".json":
+		lvl, err = world.ParseLevel(data)
+		if err == nil && lvl.Chunks != nil {
+			// Chunks are found in the maps directory the level is in.
+			world.SetAssets(os.DirFS(filepath.Dir(filepath.Dir(input))))
+			err = lvl.LoadTiles()
+		}
+	case ".txt":
+		lvl, err = readASCII(bytes.NewReader(data))
+	default:
+		log.Fatalln("unknown input format:", input)
+	}
+
+	if err != nil {
+		log.Fatalln(input+":", err)
+	}
+
+	if *chunksFlag > 0 {
+		if *outputFlag == "" {
+			log.Fatalln("-chunks needs an output directory")
+		}
+
+		name := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+		if err := world.SaveChunkedLevel(*outputFlag, name, lvl, *chunksFlag); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	var out bytes.Buffer
+	if strings.ToLower(filepath.Ext(input)) == ".json" {
+		err = writeASCII(&out, lvl)
+	} else {
+		data, err = world.MarshalLevel(lvl)
+		out.Write(data)
+	}
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if *outputFlag == "" {
+		_, err = os.Stdout.Write(out.Bytes())
+	} else {
+		err = os.WriteFile(*outputFlag, out.Bytes(), 0644)
+	}
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,379 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Command palquant reduces the textures and sprites in the assets to one shared palette
+// of at most 256 colors, for an 8-bit look. Index 0 is kept black for sprite transparency
+// and colors given with -fullbright are placed last, where ordinary pixels never map to.
+//
+//	palquant -o data8 -method kmeans -dither -fullbright ffff00,ff0000
+//
+// The palette is written to palette.json in the output directory, next to the converted
+// textures and sprites. All images are written as paletted PNG files, textures.json,
+// sprite sheets, sprites.json and the entities of the maps are rewritten to refer to them.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+)
+
+var (
+	assetsFlag     = flag.String("assets", "data", "asset directories or zip archives, separated by "+string(os.PathListSeparator))
+	outputFlag     = flag.String("o", "", "output directory")
+	colorsFlag     = flag.Int("colors", 256, "palette size, including reserved entries")
+	methodFlag     = flag.String("method", "mediancut", "quantization method, mediancut or kmeans")
+	ditherFlag     = flag.Bool("dither", false, "use Floyd-Steinberg dithering")
+	fullbrightFlag = flag.String("fullbright", "", "comma separated hex colors to reserve as fullbright")
+)
+
+// asset is an image to convert.
+type asset struct {
+	name   string
+	img    image.Image
+	sprite bool
+}
+
+// Palette is the file written to palette.json.
+type Palette struct {
+	Colors      []string
+	Transparent int
+	// Fullbright is the index of the first fullbright color.
+	Fullbright int
+}
+
+func main() {
+	log.SetFlags(0)
+	flag.Parse()
+
+	if *outputFlag == "" || flag.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: palquant -o output [flags]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	fullbright, err := parseColors(*fullbrightFlag)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if *colorsFlag > 256 || *colorsFlag < len(fullbright)+2 {
+		log.Fatalln("invalid number of colors:", *colorsFlag)
+	}
+
+	sp, err := world.OpenSearchPath(filepath.SplitList(*assetsFlag))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	world.SetAssets(sp)
+	defer world.SetAssets(nil)
+
+	textures, err := loadTextureList()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	sprites, sheets, err := listSprites()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var images []asset
+	for _, t := range textures {
+		images = append(images, asset{name: path.Join("textures", t)})
+	}
+	for _, s := range sprites {
+		images = append(images, asset{name: path.Join("sprites", s), sprite: true})
+	}
+
+	hist := make(histogram)
+	for i := range images {
+		a := &images[i]
+		if a.img, err = decodeImage(a.name); err != nil {
+			log.Fatalln(err)
+		}
+		hist.add(a.img, a.sprite, fullbright)
+	}
+
+	n := *colorsFlag - len(fullbright) - 1
+	var pal []color.RGBA
+	switch *methodFlag {
+	case "mediancut":
+		pal = medianCut(hist, n)
+	case "kmeans":
+		pal = kMeans(hist, medianCut(hist, n), 16)
+	default:
+		log.Fatalln("unknown method:", *methodFlag)
+	}
+
+	r := &remapper{
+		palette:    append(append([]color.RGBA{{A: 255}}, pal...), fullbright...),
+		fullbright: 1 + len(pal),
+		dither:     *ditherFlag,
+	}
+
+	for _, a := range images {
+		if err := writePNG(pngName(a.name), r.remap(a.img, a.sprite)); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	for i, t := range textures {
+		textures[i] = pngName(t)
+	}
+	if err := writeJSON("textures/textures.json", textures); err != nil {
+		log.Fatalln(err)
+	}
+
+	for name, sheet := range sheets {
+		sheet.Image = pngName(sheet.Image)
+		if err := writeJSON(path.Join("sprites", name), sheet); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	renamed := make(map[string]string)
+	for _, s := range sprites {
+		if name := pngName(s); name != s {
+			renamed[s] = name
+		}
+	}
+	if err := rewriteSpriteDefs(renamed); err != nil {
+		log.Fatalln(err)
+	}
+	if err := rewriteMaps(renamed); err != nil {
+		log.Fatalln(err)
+	}
+
+	out := Palette{Transparent: 0, Fullbright: r.fullbright}
+	for _, c := range r.palette {
+		out.Colors = append(out.Colors, fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B))
+	}
+	if err := writeJSON("palette.json", out); err != nil {
+		log.Fatalln(err)
+	}
+
+	log.Printf("converted %d images to %d colors", len(images), len(r.palette))
+}
+
+func parseColors(s string) ([]color.RGBA, error) {
+	var colors []color.RGBA
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimPrefix(strings.TrimSpace(f), "#")
+		if f == "" {
+			continue
+		}
+
+		v, err := strconv.ParseUint(f, 16, 32)
+		if err != nil || len(f) != 6 {
+			return nil, fmt.Errorf("invalid color: %s", f)
+		}
+		colors = append(colors, color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255})
+	}
+	return colors, nil
+}
+
+func loadTextureList() ([]string, error) {
+	data, err := fs.ReadFile(world.Assets(), "textures/textures.json")
+	if err != nil {
+		return nil, err
+	}
+
+	var textures []string
+	if err := json.Unmarshal(data, &textures); err != nil {
+		return nil, fmt.Errorf("textures.json: %v", err)
+	}
+	return textures, nil
+}
+
+// listSprites returns the sprite image files and the sprite sheets in the assets.
+func listSprites() ([]string, map[string]*world.SpriteSheet, error) {
+	entries, err := fs.ReadDir(world.Assets(), "sprites")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var files []string
+	sheets := make(map[string]*world.SpriteSheet)
+
+	for _, e := range entries {
+		name := e.Name()
+		switch strings.ToLower(path.Ext(name)) {
+		case ".png", ".gif", ".bmp", ".pcx":
+			files = append(files, name)
+		case ".json":
+			if !strings.HasSuffix(name, world.SheetExt) {
+				continue
+			}
+
+			data, err := fs.ReadFile(world.Assets(), path.Join("sprites", name))
+			if err != nil {
+				return nil, nil, err
+			}
+
+			sheet := new(world.SpriteSheet)
+			if err := json.Unmarshal(data, sheet); err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", name, err)
+			}
+			sheets[name] = sheet
+		}
+	}
+	return files, sheets, nil
+}
+
+// rewriteSpriteDefs writes sprites.json with the renamed sprites, if any of them are in it.
+func rewriteSpriteDefs(renamed map[string]string) error {
+	defs, err := world.LoadSpriteDefs()
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	out := make(world.SpriteDefs, len(defs))
+	for name, def := range defs {
+		if n, ok := renamed[name]; ok {
+			name, changed = n, true
+		}
+		out[name] = def
+	}
+
+	if !changed {
+		return nil
+	}
+	return writeJSON("sprites.json", out)
+}
+
+// rewriteMaps writes the maps that have entities with renamed sprites.
+func rewriteMaps(renamed map[string]string) error {
+	entries, err := fs.ReadDir(world.Assets(), "maps")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	done := make(map[string]bool)
+	for _, e := range entries {
+		ext := strings.ToLower(path.Ext(e.Name()))
+		name := strings.TrimSuffix(e.Name(), path.Ext(e.Name()))
+		if e.IsDir() || (ext != ".json" && ext != ".tmx") || done[name] {
+			continue
+		}
+		done[name] = true
+
+		lvl, err := world.LoadLevel(name)
+		if err != nil {
+			return err
+		}
+
+		changed := false
+		for i, ent := range lvl.Entities {
+			if n, ok := renamed[ent.Sprite]; ok {
+				lvl.Entities[i].Sprite, changed = n, true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		// LoadLevel prefers the Tiled map, which a JSON level in the output would not replace.
+		if _, err := fs.Stat(world.Assets(), path.Join("maps", name+".tmx")); err == nil {
+			return fmt.Errorf("maps/%s.tmx: can not rewrite the sprites of a Tiled map", name)
+		}
+
+		data, err := world.MarshalLevel(lvl)
+		if err != nil {
+			return fmt.Errorf("maps/%s: %v", name, err)
+		}
+		if err := writeFile(path.Join("maps", name+".json"), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeImage(name string) (image.Image, error) {
+	fp, err := world.Assets().Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	img, _, err := image.Decode(fp)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return img, nil
+}
+
+func pngName(name string) string {
+	return strings.TrimSuffix(name, path.Ext(name)) + ".png"
+}
+
+func create(name string) (*os.File, error) {
+	file := filepath.Join(*outputFlag, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(file)
+}
+
+func writePNG(name string, img image.Image) error {
+	fp, err := create(name)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(fp, img); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
+
+func writeJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+	return writeFile(name, append(data, '\n'))
+}
+
+func writeFile(name string, data []byte) error {
+	fp, err := create(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fp.Write(data); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
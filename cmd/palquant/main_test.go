@@ -0,0 +1,91 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+)
+
+func TestRewriteSpriteNames(t *testing.T) {
+	data, err := os.ReadFile("../../data/maps/level1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lvl, err := world.ParseLevel(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lvl.Entities = []world.Entity{{Sprite: "pillar.pcx"}, {Sprite: "guard.sheet.json#0"}, {Sprite: "barrel.png"}}
+	renamedLevel, err := world.MarshalLevel(lvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := fstest.MapFS{
+		"maps/a.json":           {Data: renamedLevel},
+		"maps/b.json":           {Data: data},
+		"maps/b.chunks/0_0.bin": {Data: []byte{0, 0}},
+		"sprites.json":          {Data: []byte(`{"pillar.pcx": {"Solid": true, "Radius": 0.3}, "barrel.png": {"Solid": true}}`)},
+	}
+
+	old := world.Assets()
+	world.SetAssets(fsys)
+	defer world.SetAssets(old)
+
+	*outputFlag = t.TempDir()
+	defer func() { *outputFlag = "" }()
+
+	renamed := map[string]string{"pillar.pcx": "pillar.png"}
+	if err := rewriteSpriteDefs(renamed); err != nil {
+		t.Fatal(err)
+	}
+	if err := rewriteMaps(renamed); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the level with a renamed sprite is written.
+	if _, err := os.Stat(filepath.Join(*outputFlag, "maps", "b.json")); !os.IsNotExist(err) {
+		t.Errorf("unchanged level was written: %v", err)
+	}
+
+	world.SetAssets(os.DirFS(*outputFlag))
+	out, err := world.LoadLevel("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"pillar.png", "guard.sheet.json#0", "barrel.png"}
+	for i, e := range out.Entities {
+		if e.Sprite != want[i] {
+			t.Errorf("entity %d has sprite %s, want %s", i, e.Sprite, want[i])
+		}
+	}
+
+	defs, err := world.LoadSpriteDefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := defs["pillar.pcx"]; ok || len(defs) != 2 || !defs["pillar.png"].Solid || defs["pillar.png"].Radius != 0.3 {
+		t.Errorf("sprite definitions are %v", defs)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// histogram counts the pixels of each color that is quantized.
+type histogram map[color.RGBA]int
+
+// opaque returns the color of a pixel without alpha, since the engine has none.
+func opaque(c color.Color) color.RGBA {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	rgba.A = 255
+	return rgba
+}
+
+func isTransparent(c color.RGBA) bool {
+	return c.R == 0 && c.G == 0 && c.B == 0
+}
+
+func isFullbright(c color.RGBA, fullbright []color.RGBA) bool {
+	for _, f := range fullbright {
+		if c == f {
+			return true
+		}
+	}
+	return false
+}
+
+// add counts the pixels in img, leaving out transparent sprite pixels and the
+// reserved fullbright colors.
+func (h histogram) add(img image.Image, sprite bool, fullbright []color.RGBA) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := opaque(img.At(x, y))
+			if (sprite && isTransparent(c)) || isFullbright(c, fullbright) {
+				continue
+			}
+			h[c]++
+		}
+	}
+}
+
+type weightedColor struct {
+	c [3]int
+	n int
+}
+
+func (h histogram) colors() []weightedColor {
+	colors := make([]weightedColor, 0, len(h))
+	for c, n := range h {
+		colors = append(colors, weightedColor{[3]int{int(c.R), int(c.G), int(c.B)}, n})
+	}
+
+	// Map order is random, sort to make the palette the same every run.
+	sort.Slice(colors, func(i, j int) bool {
+		a, b := colors[i].c, colors[j].c
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		if a[1] != b[1] {
+			return a[1] < b[1]
+		}
+		return a[2] < b[2]
+	})
+	return colors
+}
+
+// widestAxis returns the channel with the largest range in colors and the range.
+func widestAxis(colors []weightedColor) (axis, width int) {
+	for i := 0; i < 3; i++ {
+		lo, hi := 255, 0
+		for _, c := range colors {
+			if c.c[i] < lo {
+				lo = c.c[i]
+			}
+			if c.c[i] > hi {
+				hi = c.c[i]
+			}
+		}
+		if hi-lo > width {
+			axis, width = i, hi-lo
+		}
+	}
+	return
+}
+
+func mean(colors []weightedColor) color.RGBA {
+	var sum [3]int
+	var n int
+	for _, c := range colors {
+		for i := range sum {
+			sum[i] += c.c[i] * c.n
+		}
+		n += c.n
+	}
+	return color.RGBA{uint8((sum[0] + n/2) / n), uint8((sum[1] + n/2) / n), uint8((sum[2] + n/2) / n), 255}
+}
+
+// medianCut builds a palette of at most n colors by splitting the box with the
+// widest color range at its weighted median until there are n boxes.
+func medianCut(h histogram, n int) []color.RGBA {
+	colors := h.colors()
+	if len(colors) == 0 {
+		return nil
+	}
+
+	boxes := [][]weightedColor{colors}
+	for len(boxes) < n {
+		best, bestAxis, bestWidth := -1, 0, 0
+		for i, box := range boxes {
+			if axis, width := widestAxis(box); width > bestWidth {
+				best, bestAxis, bestWidth = i, axis, width
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		sort.SliceStable(box, func(i, j int) bool { return box[i].c[bestAxis] < box[j].c[bestAxis] })
+
+		var total, count int
+		for _, c := range box {
+			total += c.n
+		}
+
+		split := 1
+		for i, c := range box[:len(box)-1] {
+			count += c.n
+			if count*2 >= total {
+				split = i + 1
+				break
+			}
+		}
+
+		boxes[best] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+
+	pal := make([]color.RGBA, len(boxes))
+	for i, box := range boxes {
+		pal[i] = mean(box)
+	}
+	return pal
+}
+
+func distance(a [3]int, b color.RGBA) int {
+	dr, dg, db := a[0]-int(b.R), a[1]-int(b.G), a[2]-int(b.B)
+	return dr*dr + dg*dg + db*db
+}
+
+// kMeans refines a palette by moving every color to the mean of the pixels closest to it.
+func kMeans(h histogram, pal []color.RGBA, iterations int) []color.RGBA {
+	colors := h.colors()
+	assigned := make([]int, len(colors))
+
+	for it := 0; it < iterations; it++ {
+		clusters := make([][]weightedColor, len(pal))
+		changed := it == 0
+
+		for i, c := range colors {
+			best, bestDist := 0, distance(c.c, pal[0])
+			for j := 1; j < len(pal); j++ {
+				if d := distance(c.c, pal[j]); d < bestDist {
+					best, bestDist = j, d
+				}
+			}
+
+			if assigned[i] != best {
+				assigned[i] = best
+				changed = true
+			}
+			clusters[best] = append(clusters[best], c)
+		}
+
+		if !changed {
+			break
+		}
+
+		for j, cluster := range clusters {
+			if len(cluster) > 0 {
+				pal[j] = mean(cluster)
+			}
+		}
+	}
+	return pal
+}
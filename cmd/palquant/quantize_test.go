@@ -0,0 +1,91 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func testImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), uint8((x*y + x) % 256), 255})
+		}
+	}
+	return img
+}
+
+func samePalette(a, b []color.RGBA) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPaletteIsDeterministic(t *testing.T) {
+	// The histogram is a map, so every run sees the colors in a different order.
+	palette := func(method string) []color.RGBA {
+		h := make(histogram)
+		h.add(testImage(), false, nil)
+		pal := medianCut(h, 32)
+		if method == "kmeans" {
+			pal = kMeans(h, pal, 16)
+		}
+		return pal
+	}
+
+	for _, method := range []string{"mediancut", "kmeans"} {
+		want := palette(method)
+		if len(want) != 32 {
+			t.Fatalf("%s: got %d colors", method, len(want))
+		}
+		for i := 0; i < 10; i++ {
+			if got := palette(method); !samePalette(got, want) {
+				t.Fatalf("%s: palette changed between runs", method)
+			}
+		}
+	}
+}
+
+func TestHistogramSkipsReservedColors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	img.Set(0, 0, color.RGBA{0, 0, 0, 255})
+	img.Set(1, 0, color.RGBA{255, 255, 0, 255})
+	img.Set(2, 0, color.RGBA{10, 20, 30, 255})
+	fullbright := []color.RGBA{{255, 255, 0, 255}}
+
+	h := make(histogram)
+	h.add(img, true, fullbright)
+	if len(h) != 1 || h[color.RGBA{10, 20, 30, 255}] != 1 {
+		t.Errorf("sprite histogram is %v", h)
+	}
+
+	h = make(histogram)
+	h.add(img, false, fullbright)
+	if len(h) != 2 || h[color.RGBA{0, 0, 0, 255}] != 1 {
+		t.Errorf("texture histogram is %v", h)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"image"
+	"image/color"
+)
+
+// remapper maps images to a palette. Index 0 is transparent and the entries from
+// fullbright on are only used by pixels of exactly that color.
+type remapper struct {
+	palette    []color.RGBA
+	fullbright int
+	dither     bool
+}
+
+// nearest returns the closest ordinary color. Sprites can not use black, since the
+// engine would draw it as transparent.
+func (r *remapper) nearest(c [3]int, sprite bool) uint8 {
+	lo, hi := 1, r.fullbright
+	if hi <= lo {
+		// Nothing but fullbright colors to choose from.
+		hi = len(r.palette)
+	}
+
+	best, bestDist := lo, -1
+	for i := lo; i < hi; i++ {
+		if sprite && isTransparent(r.palette[i]) {
+			continue
+		}
+		if d := distance(c, r.palette[i]); bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return uint8(best)
+}
+
+func clamp(v int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return v
+}
+
+// remap converts img to a paletted image. With dithering, the error of each pixel
+// is spread to its neighbours with the Floyd-Steinberg weights.
+func (r *remapper) remap(img image.Image, sprite bool) *image.Paletted {
+	pal := make(color.Palette, len(r.palette))
+	for i, c := range r.palette {
+		pal[i] = c
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := image.NewPaletted(image.Rect(0, 0, w, h), pal)
+
+	cur, next := make([][3]int, w+2), make([][3]int, w+2)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := opaque(img.At(b.Min.X+x, b.Min.Y+y))
+			if sprite && isTransparent(c) {
+				dst.SetColorIndex(x, y, 0)
+				continue
+			}
+			if i := r.fullbrightIndex(c); i >= 0 {
+				dst.SetColorIndex(x, y, uint8(i))
+				continue
+			}
+
+			want := [3]int{int(c.R), int(c.G), int(c.B)}
+			if r.dither {
+				for i := range want {
+					want[i] = clamp(want[i] + cur[x+1][i]/16)
+				}
+			}
+
+			idx := r.nearest(want, sprite)
+			dst.SetColorIndex(x, y, idx)
+
+			if r.dither {
+				got := r.palette[idx]
+				e := [3]int{want[0] - int(got.R), want[1] - int(got.G), want[2] - int(got.B)}
+				for i := range e {
+					cur[x+2][i] += e[i] * 7
+					next[x][i] += e[i] * 3
+					next[x+1][i] += e[i] * 5
+					next[x+2][i] += e[i]
+				}
+			}
+		}
+
+		cur, next = next, cur
+		for i := range next {
+			next[i] = [3]int{}
+		}
+	}
+	return dst
+}
+
+func (r *remapper) fullbrightIndex(c color.RGBA) int {
+	for i := r.fullbright; i < len(r.palette); i++ {
+		if r.palette[i] == c {
+			return i
+		}
+	}
+	return -1
+}
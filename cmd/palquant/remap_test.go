@@ -0,0 +1,162 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRemapReservedEntries(t *testing.T) {
+	yellow := color.RGBA{255, 255, 0, 255}
+	r := &remapper{
+		palette: []color.RGBA{
+			{A: 255},
+			{0, 0, 0, 255},
+			{200, 200, 0, 255},
+			{0, 0, 255, 255},
+			yellow,
+		},
+		fullbright: 4,
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	img.Set(0, 0, color.RGBA{0, 0, 0, 255})
+	img.Set(1, 0, color.RGBA{250, 250, 0, 255})
+	img.Set(2, 0, yellow)
+	img.Set(3, 0, color.RGBA{2, 2, 2, 255})
+
+	tests := []struct {
+		sprite, dither bool
+		want           []uint8
+	}{
+		{false, false, []uint8{1, 2, 4, 1}},
+		{false, true, []uint8{1, 2, 4, 1}},
+		// Black is transparent in sprites, so a dark pixel can not use the black entry.
+		{true, false, []uint8{0, 2, 4, 3}},
+		{true, true, []uint8{0, 2, 4, 3}},
+	}
+
+	for _, tt := range tests {
+		r.dither = tt.dither
+		dst := r.remap(img, tt.sprite)
+		for x, want := range tt.want {
+			if got := dst.ColorIndexAt(x, 0); got != want {
+				t.Errorf("sprite %v, dither %v: pixel %d is %d, want %d", tt.sprite, tt.dither, x, got, want)
+			}
+		}
+	}
+}
+
+func TestRemapNeverPicksReservedEntries(t *testing.T) {
+	h := make(histogram)
+	img := testImage()
+	h.add(img, false, nil)
+
+	fullbright := []color.RGBA{{255, 255, 0, 255}, {255, 0, 0, 255}}
+	pal := medianCut(h, 16)
+	r := &remapper{
+		palette:    append(append([]color.RGBA{{A: 255}}, pal...), fullbright...),
+		fullbright: 1 + len(pal),
+	}
+
+	for _, dither := range []bool{false, true} {
+		r.dither = dither
+		dst := r.remap(img, false)
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				c := img.RGBAAt(x, y)
+				i := int(dst.ColorIndexAt(x, y))
+				if i == 0 || (i >= r.fullbright && !isFullbright(c, fullbright)) {
+					t.Fatalf("dither %v: pixel %d,%d of color %v maps to reserved entry %d", dither, x, y, c, i)
+				}
+			}
+		}
+	}
+}
+
+func grayRemapper(dither bool) *remapper {
+	return &remapper{
+		palette:    []color.RGBA{{A: 255}, {0, 0, 0, 255}, {255, 255, 255, 255}},
+		fullbright: 3,
+		dither:     dither,
+	}
+}
+
+func grayImage(w, h int, gray func(x, y int) uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := gray(x, y)
+			img.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestRemapDitherWeights(t *testing.T) {
+	// Worked by hand: the error of 64 spreads 7/16 right, 3/16, 5/16 and 1/16 below.
+	img := grayImage(2, 2, func(x, y int) uint8 { return 64 })
+	dst := grayRemapper(true).remap(img, false)
+
+	want := []uint8{1, 1, 1, 2}
+	for i, idx := range want {
+		if got := dst.ColorIndexAt(i%2, i/2); got != idx {
+			t.Errorf("pixel %d,%d is %d, want %d", i%2, i/2, got, idx)
+		}
+	}
+}
+
+func TestRemapDitherGradient(t *testing.T) {
+	// Columns go from black to white. Without dithering every pixel snaps to
+	// the nearest color, with dithering each band keeps its average brightness.
+	const w, h, band = 64, 32, 8
+	img := grayImage(w, h, func(x, y int) uint8 { return uint8(x*4 + 2) })
+
+	plain := grayRemapper(false).remap(img, false)
+	for x := 0; x < w; x++ {
+		want := uint8(1)
+		if x*4+2 > 127 {
+			want = 2
+		}
+		for y := 0; y < h; y++ {
+			if got := plain.ColorIndexAt(x, y); got != want {
+				t.Fatalf("without dithering pixel %d,%d is %d, want %d", x, y, got, want)
+			}
+		}
+	}
+
+	dithered := grayRemapper(true).remap(img, false)
+	for x0 := 0; x0 < w; x0 += band {
+		var in, out int
+		for y := 0; y < h; y++ {
+			for x := x0; x < x0+band; x++ {
+				in += x*4 + 2
+				if dithered.ColorIndexAt(x, y) == 2 {
+					out += 255
+				}
+			}
+		}
+
+		in, out = in/(band*h), out/(band*h)
+		if d := in - out; d < -8 || d > 8 {
+			t.Errorf("band at %d has brightness %d, want %d", x0, out, in)
+		}
+	}
+}
@@ -27,6 +27,7 @@ import (
 
 type Raycaster struct {
 	pos, dir, plane vec2.T
+	pixelAspect     float64
 	renderTarget    RenderTarget
 	world           World
 }
@@ -51,7 +52,7 @@ type World interface {
 func NewRaycaster(rt RenderTarget, w World) *Raycaster {
 	return &Raycaster{
 		dir:          vec2.T{-1.0, 0.0},
-		plane:        vec2.T{0.0, 0.66},
+		pixelAspect:  1,
 		renderTarget: rt,
 		world:        w,
 	}
@@ -61,10 +62,24 @@ func (rc *Raycaster) Rotate(r float64) {
 	tmp := rc.dir[0]
 	rc.dir[0] = rc.dir[0]*math.Cos(r) - rc.dir[1]*math.Sin(r)
 	rc.dir[1] = tmp*math.Sin(r) + rc.dir[1]*math.Cos(r)
+}
+
+// SetPixelAspect sets the width/height ratio of one render target pixel as it appears on the display.
+func (rc *Raycaster) SetPixelAspect(a float64) {
+	rc.pixelAspect = a
+}
+
+func (rc *Raycaster) SetWorld(w World) {
+	rc.world = w
+}
 
-	tmp = rc.plane[0]
-	rc.plane[0] = rc.plane[0]*math.Cos(r) - rc.plane[1]*math.Sin(r)
-	rc.plane[1] = tmp*math.Sin(r) + rc.plane[1]*math.Cos(r)
+func (rc *Raycaster) SetPos(p vec2.T) {
+	rc.pos = p
+}
+
+// SetAngle points the camera in direction a, in radians from the positive X axis.
+func (rc *Raycaster) SetAngle(a float64) {
+	rc.dir = vec2.T{math.Cos(a), math.Sin(a)}
 }
 
 func (rc *Raycaster) Move(v vec2.T) {
@@ -79,10 +94,19 @@ func (rc *Raycaster) Pos() vec2.T {
 	return rc.pos
 }
 
+// updatePlane fits the camera plane to the render target so the vertical field of view
+// stays the same and the horizontal one follows the aspect ratio.
+func (rc *Raycaster) updatePlane(size image.Point) {
+	ln := float64(size.X) * rc.pixelAspect / (2 * float64(size.Y))
+	rc.plane = vec2.T{rc.dir[1] * ln, -rc.dir[0] * ln}
+}
+
 func (rc *Raycaster) Render() {
 	// Reference: http://lodev.org/cgtutor/raycasting.html
 
 	rtSize := rc.renderTarget.Bounds().Size()
+	rc.updatePlane(rtSize)
+
 	for x := 0; x < rtSize.X; x++ {
 		// Calculate ray position and direction.
 		cameraX := 2*float64(x)/float64(rtSize.X) - 1 // X coordinate in camera space.
@@ -0,0 +1,232 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package engine_test
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/andreas-jonsson/go-wolf/engine"
+	"github.com/andreas-jonsson/go-wolf/world"
+	"github.com/ungerik/go3d/float64/vec2"
+)
+
+var updateGolden = flag.Bool("update", false, "regenerate golden images")
+
+const (
+	goldenPath = "engine/testdata"
+	failedPath = "engine/testdata/failed"
+
+	channelTolerance = 8     // Max difference per color channel before a pixel counts as changed.
+	pixelTolerance   = 0.001 // Max fraction of changed pixels.
+)
+
+type memoryTarget struct {
+	*image.RGBA
+	depth []float64
+}
+
+func newMemoryTarget(w, h int) *memoryTarget {
+	return &memoryTarget{
+		RGBA:  image.NewRGBA(image.Rect(0, 0, w, h)),
+		depth: make([]float64, w),
+	}
+}
+
+func (rt *memoryTarget) SetZ(x int, z float64) {
+	rt.depth[x] = z
+}
+
+func (rt *memoryTarget) GetZ(x int) float64 {
+	return rt.depth[x]
+}
+
+func (rt *memoryTarget) clear() {
+	size := rt.Bounds().Size()
+	roofColor := color.RGBA{75, 75, 75, 255}
+	floorColor := color.RGBA{100, 100, 100, 255}
+
+	for y := 0; y < size.Y; y++ {
+		c := roofColor
+		if y > size.Y/2 {
+			c = floorColor
+		}
+		for x := 0; x < size.X; x++ {
+			rt.SetRGBA(x, y, c)
+		}
+	}
+}
+
+var cameras = []struct {
+	name       string
+	pos        vec2.T
+	angle      float64
+	resolution image.Point
+	aspect     float64
+}{
+	{"start", vec2.T{22, 11.5}, 0, image.Point{320, 200}, 5.0 / 6.0},
+	{"start_back", vec2.T{22, 11.5}, math.Pi, image.Point{320, 200}, 5.0 / 6.0},
+	{"corridor", vec2.T{12.5, 11.5}, math.Pi / 2, image.Point{320, 200}, 5.0 / 6.0},
+	{"room", vec2.T{6.5, 3.5}, 2.3, image.Point{320, 200}, 5.0 / 6.0},
+	{"sprites_wide", vec2.T{19.5, 13.8}, -math.Pi / 2, image.Point{400, 225}, 1},
+	{"start_hires", vec2.T{22, 11.5}, 0.4, image.Point{640, 400}, 5.0 / 6.0},
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	// Assets are loaded relative to the repository root.
+	if err := os.Chdir(".."); err != nil {
+		log.Panicln(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestRenderGolden(t *testing.T) {
+	w, err := world.NewWorld("level1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sprites, err := world.LoadSprites("level1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cam := range cameras {
+		rt := newMemoryTarget(cam.resolution.X, cam.resolution.Y)
+		rt.clear()
+
+		rc := engine.NewRaycaster(rt, w)
+		rc.SetPixelAspect(cam.aspect)
+		rc.Move(cam.pos)
+		rc.Rotate(cam.angle)
+
+		rc.Render()
+		engine.NewSpritecaster(sprites).Render(rc)
+
+		t.Run(cam.name, func(t *testing.T) {
+			compareGolden(t, cam.name, rt.RGBA)
+		})
+	}
+}
+
+func compareGolden(t *testing.T, name string, img *image.RGBA) {
+	goldenFile := path.Join(goldenPath, name+".png")
+	if *updateGolden {
+		if err := writePNG(goldenFile, img); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	golden, err := readPNG(goldenFile)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+
+	if golden.Bounds() != img.Bounds() {
+		t.Fatalf("size mismatch: got %v, golden %v", img.Bounds(), golden.Bounds())
+	}
+
+	diff, numDiff := diffImages(golden, img)
+	size := img.Bounds().Size()
+	if float64(numDiff) <= pixelTolerance*float64(size.X*size.Y) {
+		return
+	}
+
+	if err := os.MkdirAll(failedPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	gotFile := path.Join(failedPath, name+".got.png")
+	diffFile := path.Join(failedPath, name+".diff.png")
+
+	if err := writePNG(gotFile, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePNG(diffFile, diff); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Errorf("%d pixels differ from %s, see %s and %s", numDiff, goldenFile, gotFile, diffFile)
+}
+
+// diffImages returns an image where changed pixels are red over a dimmed copy of the golden image.
+func diffImages(golden image.Image, img *image.RGBA) (*image.RGBA, int) {
+	var (
+		numDiff int
+		bounds  = img.Bounds()
+		diff    = image.NewRGBA(bounds)
+	)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			a := color.RGBAModel.Convert(golden.At(x, y)).(color.RGBA)
+			b := img.RGBAAt(x, y)
+
+			if channelDiff(a.R, b.R) > channelTolerance || channelDiff(a.G, b.G) > channelTolerance || channelDiff(a.B, b.B) > channelTolerance {
+				diff.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+				numDiff++
+			} else {
+				diff.SetRGBA(x, y, color.RGBA{a.R / 4, a.G / 4, a.B / 4, 255})
+			}
+		}
+	}
+
+	return diff, numDiff
+}
+
+func channelDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+func readPNG(name string) (image.Image, error) {
+	fp, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	img, err := png.Decode(fp)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return img, nil
+}
+
+func writePNG(name string, img image.Image) error {
+	fp, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	return png.Encode(fp, img)
+}
@@ -87,7 +87,7 @@ func (sc *Spritecaster) Render(rc *Raycaster) {
 		}
 
 		// Calculate width of the sprite.
-		spriteWidth := int(math.Abs(float64(rtSize.Y) / (transformY)))
+		spriteWidth := int(math.Abs(float64(rtSize.Y) / (transformY * rc.pixelAspect)))
 		drawStartX := -spriteWidth/2 + spriteScreenX
 		if drawStartX < 0 {
 			drawStartX = 0
@@ -0,0 +1,71 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package entry
+
+import (
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+)
+
+var assetsFlag = flag.String("assets", "", "asset directories or zip archives searched before the defaults, separated by "+string(os.PathListSeparator))
+
+// openAssets builds the asset search path. Files in -assets override the data directory
+// in the working directory, which overrides the one next to the executable. Assets
+// embedded in the binary are searched last.
+func openAssets() (fs.FS, error) {
+	var names []string
+	if *assetsFlag != "" {
+		names = filepath.SplitList(*assetsFlag)
+	}
+
+	dirs := []string{"data"}
+	if exe, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Join(filepath.Dir(exe), "data"))
+	}
+
+	for _, dir := range dirs {
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			names = append(names, dir)
+		}
+	}
+
+	sp, err := world.OpenSearchPath(names)
+	if err != nil {
+		return nil, err
+	}
+
+	if embeddedAssets != nil {
+		sp = append(sp, embeddedAssets)
+	}
+	return sp, nil
+}
+
+// saveDir returns the first asset directory in -assets, where the editor saves levels
+// so they override the ones in the data directory.
+func saveDir() string {
+	for _, name := range filepath.SplitList(*assetsFlag) {
+		if fi, err := os.Stat(name); err == nil && fi.IsDir() {
+			return name
+		}
+	}
+	return ""
+}
@@ -18,31 +18,182 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package entry
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/andreas-jonsson/go-wolf/engine"
 	"github.com/andreas-jonsson/go-wolf/game"
+	"github.com/andreas-jonsson/go-wolf/game/edit"
 	"github.com/andreas-jonsson/go-wolf/game/menu"
 	"github.com/andreas-jonsson/go-wolf/game/play"
 	"github.com/andreas-jonsson/go-wolf/platform"
+	"github.com/andreas-jonsson/go-wolf/world"
+	"github.com/andreas-jonsson/go-wolf/world/gen"
 )
 
+var (
+	resolutionFlag   = flag.String("res", "320x200", "internal resolution")
+	scaleFlag        = flag.String("scale", "fit", "scaling mode: fit, integer or stretch")
+	squarePixelsFlag = flag.Bool("square", false, "show 200-line modes with square pixels")
+	backendFlag      = flag.String("backend", "sdl", "platform backend: sdl, headless, terminal or vnc")
+	vncAddrFlag      = flag.String("vncaddr", "localhost:5900", "address the vnc backend listens on")
+	framesFlag       = flag.Int("frames", 0, "quit after this many frames (0 runs until quit)")
+	frameDumpFlag    = flag.String("dump", "", "directory to write every frame to (headless only)")
+	screenshotFlag   = flag.Int("shotscale", 1, "render screenshots at this many times the resolution")
+	wolf3dFlag       = flag.String("wolf3d", "", "directory with original Wolfenstein 3D data files to play")
+	wolf3dMapFlag    = flag.Int("map", 0, "map number to import from the Wolfenstein 3D data files")
+	recordFlag       = flag.Bool("record", false, "start recording at launch (toggle with F11)")
+	recordFmtFlag    = flag.String("recfmt", "gif", "recording format: gif or png")
+	recordFPSFlag    = flag.Int("recfps", 15, "recording frame rate")
+	endlessFlag      = flag.Bool("endless", false, "play an endless run of generated levels")
+	seedFlag         = flag.Int64("seed", 0, "seed of the first generated level (0 picks one at random)")
+)
+
+func rendererConfig() ([]platform.Config, error) {
+	res, err := platform.ParseResolution(*resolutionFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := platform.ParseScaleMode(*scaleFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := []platform.Config{
+		platform.ConfigWithDiv(2),
+		platform.ConfigWithNoVSync,
+		platform.ConfigWithResolution(res.X, res.Y),
+		platform.ConfigWithScaleMode(mode),
+	}
+
+	if *squarePixelsFlag {
+		configs = append(configs, platform.ConfigWithSquarePixels)
+	}
+	if *frameDumpFlag != "" {
+		configs = append(configs, platform.ConfigWithFrameDump(*frameDumpFlag))
+	}
+	return configs, nil
+}
+
+func newRenderer(configs []platform.Config) (platform.Renderer, error) {
+	switch *backendFlag {
+	case "sdl":
+		if err := platform.Init(); err != nil {
+			return nil, err
+		}
+		return platform.NewRenderer(configs...)
+	case "headless":
+		platform.SetEventSource(platform.NewHeadlessEventSource())
+		return platform.NewHeadlessRenderer(configs...)
+	case "terminal":
+		src, err := platform.NewTerminalEventSource()
+		if err != nil {
+			return nil, err
+		}
+		platform.SetEventSource(src)
+		return platform.NewTerminalRenderer(configs...)
+	case "vnc":
+		rnd, err := platform.NewVNCRenderer(*vncAddrFlag, configs...)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("Serving VNC on", rnd.Addr())
+		platform.SetEventSource(rnd)
+		return rnd, nil
+	}
+	return nil, fmt.Errorf("invalid backend: %s", *backendFlag)
+}
+
 func Entry() {
-	if err := platform.Init(); err != nil {
+	flag.Parse()
+
+	configs, err := rendererConfig()
+	if err != nil {
 		log.Panicln(err)
 	}
+
+	if *screenshotFlag < 1 {
+		log.Panicln("invalid screenshot scale:", *screenshotFlag)
+	}
+
 	defer platform.Shutdown()
+	defer platform.SetEventSource(nil)
 
-	//rnd, err := platform.NewRenderer(platform.ConfigWithFullscreen, platform.ConfigWithNoVSync)
-	rnd, err := platform.NewRenderer(platform.ConfigWithDiv(2), platform.ConfigWithNoVSync) //, platform.ConfigWithDebug)
+	assets, err := openAssets()
+	if err != nil {
+		log.Panicln(err)
+	}
+	world.SetAssets(assets)
+	defer world.SetAssets(nil)
+
+	recordFmt, err := platform.ParseRecordFormat(*recordFmtFlag)
+	if err != nil {
+		log.Panicln(err)
+	}
+
+	rnd, err := newRenderer(configs)
 	if err != nil {
 		log.Panicln(err)
 	}
 	defer rnd.Shutdown()
 
+	rec := platform.NewRecorder(rnd, recordFmt, *recordFPSFlag)
+	if *recordFlag {
+		if err := rec.Start(); err != nil {
+			log.Panicln(err)
+		}
+	}
+
+	defer func() {
+		if rec.Recording() {
+			if name, err := rec.Stop(); err != nil {
+				log.Println("Could not save recording:", err)
+			} else {
+				log.Println("Saved recording:", name)
+			}
+		}
+	}()
+
+	playState := play.NewPlayState(rnd.PixelAspect())
+	if *wolf3dFlag != "" {
+		w, sprites, err := world.ImportWolf3D(*wolf3dFlag, *wolf3dMapFlag)
+		if err != nil {
+			log.Panicln(err)
+		}
+		playState.SetWorld(w, sprites)
+	} else if *endlessFlag {
+		seed := *seedFlag
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+
+		next := func() (*world.World, engine.SpriteInstances, error) {
+			log.Println("Generating level with seed:", seed)
+			w, sprites, err := gen.NewWorld(seed)
+			seed++
+			return w, sprites, err
+		}
+
+		w, sprites, err := next()
+		if err != nil {
+			log.Panicln(err)
+		}
+		playState.SetWorld(w, sprites)
+		playState.SetNextLevel(next)
+	}
+
+	editState := edit.NewEditState(rnd.PixelAspect())
+	if dir := saveDir(); dir != "" {
+		editState.SetSaveDir(dir)
+	}
+
 	states := map[string]game.GameState{
 		"menu": menu.NewMenuState(),
-		"play": play.NewPlayState(),
+		"play": playState,
+		"edit": editState,
 	}
 
 	g, err := game.NewGame(states)
@@ -50,13 +201,20 @@ func Entry() {
 		log.Panicln(err)
 	}
 	defer g.Shutdown()
+	g.SetScreenshotScale(*screenshotFlag)
+	g.SetRecorder(rec)
 
 	var gctl game.GameControl = g
 	if err := g.SwitchState("menu", gctl); err != nil {
 		log.Panicln(err)
 	}
 
-	for g.Running() {
+	for numFrames := 0; g.Running(); numFrames++ {
+		if *framesFlag > 0 && numFrames >= *framesFlag {
+			g.Terminate()
+			break
+		}
+
 		//rnd.Clear()
 
 		if err := g.Update(); err != nil {
@@ -70,6 +228,6 @@ func Entry() {
 			log.Panicln(err)
 		}
 
-		rnd.Present()
+		rec.Present()
 	}
 }
@@ -0,0 +1,452 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package edit is a level editor game state.
+//
+// Arrows move the cursor and Return paints the selected tile, which is picked with
+// 0-9 or cycled with + and -. P places the selected sprite, cycled with N, X deletes
+// sprites in the cursor tile and M picks one up to move it. S sets the player start
+// and , and . turn the preview camera. W saves the level and E goes back to playing.
+// The left mouse button paints and the right one erases.
+package edit
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"log"
+	"math"
+
+	"github.com/andreas-jonsson/go-wolf/engine"
+	"github.com/andreas-jonsson/go-wolf/game"
+	"github.com/andreas-jonsson/go-wolf/platform"
+	"github.com/andreas-jonsson/go-wolf/world"
+	"github.com/ungerik/go3d/float64/vec2"
+)
+
+const statusHeight = game.GlyphHeight*2 + 4
+
+var (
+	emptyColor  = color.RGBA{32, 32, 32, 255}
+	spriteColor = color.RGBA{255, 220, 0, 255}
+	startColor  = color.RGBA{0, 255, 0, 255}
+	exitColor   = color.RGBA{255, 0, 255, 255}
+	cursorColor = color.RGBA{255, 255, 255, 255}
+	statusColor = color.RGBA{0, 0, 64, 255}
+)
+
+// previewTarget is a render target for a part of the backbuffer.
+type previewTarget struct {
+	backBuffer draw.Image
+	rect       image.Rectangle
+	depth      []float64
+}
+
+func (pt *previewTarget) Bounds() image.Rectangle {
+	return image.Rectangle{Max: pt.rect.Size()}
+}
+
+func (pt *previewTarget) Set(x, y int, c color.Color) {
+	pt.backBuffer.Set(pt.rect.Min.X+x, pt.rect.Min.Y+y, c)
+}
+
+func (pt *previewTarget) SetZ(x int, z float64) {
+	pt.depth[x] = z
+}
+
+func (pt *previewTarget) GetZ(x int) float64 {
+	return pt.depth[x]
+}
+
+type editState struct {
+	name    string
+	w       *world.World
+	saveDir string
+
+	pt *previewTarget
+	rc *engine.Raycaster
+	sc *engine.Spritecaster
+
+	tileColors []color.RGBA
+	spriteList []string
+
+	cursor      image.Point
+	angle       float64
+	brush       int
+	sprite      int
+	carried     *world.Entity
+	status      string
+	mapRect     image.Rectangle
+	mapOrigin   image.Point
+	cellSize    int
+	pixelAspect float64
+}
+
+func NewEditState(pixelAspect float64) *editState {
+	return &editState{saveDir: "data", pixelAspect: pixelAspect, brush: 1}
+}
+
+// SetSaveDir sets the directory levels are saved to. The level goes in its maps directory.
+func (s *editState) SetSaveDir(dir string) {
+	s.saveDir = dir
+}
+
+func (s *editState) Name() string {
+	return "edit"
+}
+
+// Enter expects the name of the level and the world to edit. The world is changed in place.
+func (s *editState) Enter(from game.GameState, args ...interface{}) error {
+	if len(args) < 2 {
+		return fmt.Errorf("edit state needs a level name and a world")
+	}
+
+	name, _ := args[0].(string)
+	w, _ := args[1].(*world.World)
+	if name == "" || w == nil {
+		return fmt.Errorf("invalid level to edit")
+	}
+
+	s.name, s.w = name, w
+	s.pt = new(previewTarget)
+	s.rc = engine.NewRaycaster(s.pt, w)
+	s.rc.SetPixelAspect(s.pixelAspect)
+
+	s.tileColors = nil
+	for i := 0; i < w.NumTextures(); i++ {
+		s.tileColors = append(s.tileColors, averageColor(w.GetTexture(i, 0)))
+	}
+
+	s.spriteList, _ = world.SpriteNames()
+
+	pos, angle := w.Start()
+	s.cursor = image.Pt(int(pos[0]), int(pos[1]))
+	s.angle = angle
+	s.carried = nil
+	s.status = "Editing " + name
+
+	return s.updateSprites()
+}
+
+func (s *editState) Exit(to game.GameState) error {
+	return nil
+}
+
+func (s *editState) updateSprites() error {
+	sprites, err := s.w.Sprites()
+	if err != nil {
+		return err
+	}
+	s.sc = engine.NewSpritecaster(sprites)
+	return nil
+}
+
+func (s *editState) Update(gctl game.GameControl) error {
+	for event := gctl.PollEvent(); event != nil; event = gctl.PollEvent() {
+		switch t := event.(type) {
+		case *platform.QuitEvent:
+			gctl.Terminate()
+		case *platform.KeyDownEvent:
+			if err := s.handleKey(gctl, t); err != nil {
+				return err
+			}
+		case *platform.MouseButtonDownEvent:
+			p := image.Pt(t.X, t.Y)
+			if !p.In(s.mapRect) || s.cellSize == 0 {
+				break
+			}
+
+			cell := p.Sub(s.mapRect.Min).Div(s.cellSize).Add(s.mapOrigin)
+			s.cursor = image.Pt(cell.Y, cell.X)
+			s.clampCursor()
+
+			switch t.Button {
+			case 1:
+				s.paint(s.brush)
+			case 3:
+				s.paint(0)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *editState) handleKey(gctl game.GameControl, ev *platform.KeyDownEvent) error {
+	switch ev.Key {
+	case platform.KeyUp:
+		s.cursor.X--
+	case platform.KeyDown:
+		s.cursor.X++
+	case platform.KeyLeft:
+		s.cursor.Y--
+	case platform.KeyRight:
+		s.cursor.Y++
+	case platform.KeyReturn:
+		s.paint(s.brush)
+	}
+	s.clampCursor()
+
+	numTiles := len(s.tileColors) + 1
+	switch r := ev.Rune; {
+	case r >= '0' && r <= '9':
+		if int(r-'0') < numTiles {
+			s.brush = int(r - '0')
+		}
+	case r == '+' || r == '=':
+		s.brush = (s.brush + 1) % numTiles
+	case r == '-':
+		s.brush = (s.brush + numTiles - 1) % numTiles
+	case r == ',':
+		s.angle += math.Pi / 8
+	case r == '.':
+		s.angle -= math.Pi / 8
+	case r == 'n' || r == 'N':
+		if len(s.spriteList) > 0 {
+			s.sprite = (s.sprite + 1) % len(s.spriteList)
+		}
+	case r == 'p' || r == 'P':
+		if len(s.spriteList) > 0 {
+			s.w.AddSprite(world.Entity{Pos: s.cursorPos(), Sprite: s.spriteList[s.sprite]})
+			return s.updateSprites()
+		}
+	case r == 'x' || r == 'X':
+		s.removeSprites()
+		return s.updateSprites()
+	case r == 'm' || r == 'M':
+		return s.moveSprite()
+	case r == 's' || r == 'S':
+		pos := s.cursorPos()
+		s.w.SetStart(vec2.T{pos[0], pos[1]}, s.angle)
+		s.status = "Player start set"
+	case r == 'w' || r == 'W':
+		if err := world.SaveWorld(s.saveDir, s.name, s.w); err != nil {
+			s.status = "Could not save: " + err.Error()
+			log.Println("Could not save level:", err)
+		} else {
+			s.status = "Saved " + s.name
+			log.Println("Saved level:", s.name)
+		}
+	case r == 'e' || r == 'E':
+		return gctl.SwitchState("play", s.w)
+	}
+	return nil
+}
+
+func (s *editState) clampCursor() {
+	rows, cols := s.w.Size()
+	s.cursor.X = clamp(s.cursor.X, 0, rows-1)
+	s.cursor.Y = clamp(s.cursor.Y, 0, cols-1)
+}
+
+func (s *editState) cursorPos() [2]float64 {
+	return [2]float64{float64(s.cursor.X) + 0.5, float64(s.cursor.Y) + 0.5}
+}
+
+func (s *editState) paint(tile int) {
+	s.w.SetTile(s.cursor.X, s.cursor.Y, tile)
+}
+
+func (s *editState) removeSprites() {
+	for i := s.w.SpriteAt(s.cursor.X, s.cursor.Y); i >= 0; i = s.w.SpriteAt(s.cursor.X, s.cursor.Y) {
+		s.w.RemoveSprite(i)
+	}
+}
+
+// moveSprite picks up a sprite in the cursor tile, or puts down the one carried.
+func (s *editState) moveSprite() error {
+	if s.carried != nil {
+		e := *s.carried
+		e.Pos = s.cursorPos()
+		s.w.AddSprite(e)
+		s.carried = nil
+		s.status = "Moved " + e.Sprite
+		return s.updateSprites()
+	}
+
+	if i := s.w.SpriteAt(s.cursor.X, s.cursor.Y); i >= 0 {
+		e := s.w.RemoveSprite(i)
+		s.carried = &e
+		s.status = "Carrying " + e.Sprite
+		return s.updateSprites()
+	}
+	return nil
+}
+
+func (s *editState) Render(backBuffer draw.Image) error {
+	bounds := backBuffer.Bounds()
+	draw.Draw(backBuffer, bounds, image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	split := bounds.Min.X + bounds.Dx()/2
+	s.mapRect = image.Rect(bounds.Min.X, bounds.Min.Y, split, bounds.Max.Y-statusHeight)
+	s.renderMap(backBuffer)
+
+	preview := image.Rect(split+1, bounds.Min.Y, bounds.Max.X, bounds.Max.Y-statusHeight)
+	if !preview.Empty() {
+		s.renderPreview(backBuffer, preview)
+	}
+
+	s.renderStatus(backBuffer, image.Rect(bounds.Min.X, bounds.Max.Y-statusHeight, bounds.Max.X, bounds.Max.Y))
+	return nil
+}
+
+func (s *editState) renderMap(backBuffer draw.Image) {
+	rows, cols := s.w.Size()
+	size := s.mapRect.Size()
+
+	// A level without tiles has nothing to draw or click on.
+	if rows == 0 || cols == 0 {
+		s.cellSize = 0
+		draw.Draw(backBuffer, s.mapRect, image.NewUniform(emptyColor), image.Point{}, draw.Src)
+		return
+	}
+
+	// Cells are at least four pixels. Larger levels scroll to keep the cursor in view.
+	s.cellSize = size.X / cols
+	if c := size.Y / rows; c < s.cellSize {
+		s.cellSize = c
+	}
+	if s.cellSize < 4 {
+		s.cellSize = 4
+	}
+
+	visible := size.Div(s.cellSize)
+	s.mapOrigin = image.Pt(
+		clamp(s.cursor.Y-visible.X/2, 0, max(cols-visible.X, 0)),
+		clamp(s.cursor.X-visible.Y/2, 0, max(rows-visible.Y, 0)),
+	)
+
+	cellRect := func(x, y int) image.Rectangle {
+		min := image.Pt(y, x).Sub(s.mapOrigin).Mul(s.cellSize).Add(s.mapRect.Min)
+		return image.Rectangle{min, min.Add(image.Pt(s.cellSize, s.cellSize))}.Intersect(s.mapRect)
+	}
+	fill := func(r image.Rectangle, c color.Color) {
+		draw.Draw(backBuffer, r, image.NewUniform(c), image.Point{}, draw.Src)
+	}
+
+	// Only the visible tiles are read, so large chunked maps are not loaded every frame.
+	for x := s.mapOrigin.Y; x < rows && x <= s.mapOrigin.Y+visible.Y; x++ {
+		for y := s.mapOrigin.X; y < cols && y <= s.mapOrigin.X+visible.X; y++ {
+			t, c := s.w.GetTile(x, y), emptyColor
+			if t > 0 && t <= len(s.tileColors) {
+				c = s.tileColors[t-1]
+			}
+			fill(cellRect(x, y), c)
+		}
+	}
+
+	dot := func(pos [2]float64, c color.Color) {
+		r := cellRect(int(pos[0]), int(pos[1]))
+		fill(r.Inset(s.cellSize/4), c)
+	}
+
+	for _, e := range s.w.Entities() {
+		dot(e.Pos, spriteColor)
+	}
+	if x, y, ok := s.w.Exit(); ok {
+		dot([2]float64{float64(x), float64(y)}, exitColor)
+	}
+	start, _ := s.w.Start()
+	dot([2]float64{start[0], start[1]}, startColor)
+
+	// Mark the cursor and the direction of the preview camera.
+	r := cellRect(s.cursor.X, s.cursor.Y)
+	for i := r.Min.X; i < r.Max.X; i++ {
+		backBuffer.Set(i, r.Min.Y, cursorColor)
+		backBuffer.Set(i, r.Max.Y-1, cursorColor)
+	}
+	for i := r.Min.Y; i < r.Max.Y; i++ {
+		backBuffer.Set(r.Min.X, i, cursorColor)
+		backBuffer.Set(r.Max.X-1, i, cursorColor)
+	}
+
+	center := r.Min.Add(r.Max).Div(2)
+	for i := 0; i < s.cellSize; i++ {
+		// Map X is drawn downwards and map Y to the right.
+		dx, dy := math.Sin(s.angle)*float64(i), math.Cos(s.angle)*float64(i)
+		p := image.Pt(center.X+int(dx), center.Y+int(dy))
+		if p.In(s.mapRect) {
+			backBuffer.Set(p.X, p.Y, cursorColor)
+		}
+	}
+}
+
+func (s *editState) renderPreview(backBuffer draw.Image, rect image.Rectangle) {
+	if s.pt.backBuffer != backBuffer || s.pt.rect != rect {
+		s.pt.backBuffer = backBuffer
+		s.pt.rect = rect
+		s.pt.depth = make([]float64, rect.Dx())
+	}
+
+	sky := s.w.Sky().Color
+	half := rect.Min.Y + rect.Dy()/2
+	draw.Draw(backBuffer, image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, half), image.NewUniform(color.RGBA{sky[0], sky[1], sky[2], 255}), image.Point{}, draw.Src)
+	draw.Draw(backBuffer, image.Rect(rect.Min.X, half, rect.Max.X, rect.Max.Y), image.NewUniform(color.RGBA{100, 100, 100, 255}), image.Point{}, draw.Src)
+
+	pos := s.cursorPos()
+	s.rc.SetPos(vec2.T{pos[0], pos[1]})
+	s.rc.SetAngle(s.angle)
+	s.rc.Render()
+	s.sc.Render(s.rc)
+}
+
+func (s *editState) renderStatus(backBuffer draw.Image, rect image.Rectangle) {
+	draw.Draw(backBuffer, rect, image.NewUniform(statusColor), image.Point{}, draw.Src)
+
+	sprite := "-"
+	if len(s.spriteList) > 0 {
+		sprite = s.spriteList[s.sprite]
+	}
+	info := fmt.Sprintf("%d,%d tile %d brush %d sprite %s", s.cursor.X, s.cursor.Y, s.w.GetTile(s.cursor.X, s.cursor.Y), s.brush, sprite)
+
+	game.DrawText(backBuffer, rect.Min.X+2, rect.Min.Y+2, info, color.White)
+	game.DrawText(backBuffer, rect.Min.X+2, rect.Min.Y+2+game.GlyphHeight, s.status, color.White)
+}
+
+// averageColor is the color a tile is drawn with on the map.
+func averageColor(img engine.Texture) color.RGBA {
+	var r, g, b, n uint64
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			cr, cg, cb, _ := img.At(x, y).RGBA()
+			r, g, b, n = r+uint64(cr>>8), g+uint64(cg>>8), b+uint64(cb>>8), n+1
+		}
+	}
+
+	if n == 0 {
+		return color.RGBA{A: 255}
+	}
+	return color.RGBA{uint8(r / n), uint8(g / n), uint8(b / n), 255}
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
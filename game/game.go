@@ -6,7 +6,9 @@
 package game
 
 import (
+	"errors"
 	"fmt"
+	"image"
 	"image/draw"
 	"log"
 	"time"
@@ -29,6 +31,7 @@ type (
 		Timing() (time.Duration, time.Duration, int)
 		PollAll()
 		PollEvent() platform.Event
+		Screenshot(scale int) (string, error)
 		Terminate()
 	}
 )
@@ -44,10 +47,14 @@ type Game struct {
 	dt, tick  time.Duration
 	numFrames int
 	running   bool
+
+	backBuffer      draw.Image
+	screenshotScale int
+	recorder        *platform.Recorder
 }
 
 func NewGame(states map[string]GameState) (*Game, error) {
-	return &Game{running: true, states: states, t: time.Now()}, nil
+	return &Game{running: true, states: states, t: time.Now(), screenshotScale: 1}, nil
 }
 
 func (g *Game) PollAll() {
@@ -70,6 +77,16 @@ func (g *Game) PollEvent() platform.Event {
 			case platform.KeyEsc:
 				g.running = false
 				continue
+			case platform.KeyF12:
+				if name, err := g.Screenshot(g.screenshotScale); err != nil {
+					log.Println("Could not save screenshot:", err)
+				} else {
+					log.Println("Saved screenshot:", name)
+				}
+				continue
+			case platform.KeyF11:
+				g.toggleRecording()
+				continue
 			}
 			return event
 		default:
@@ -139,11 +156,65 @@ func (g *Game) Update() error {
 }
 
 func (g *Game) Render(backBuffer draw.Image) error {
+	g.backBuffer = backBuffer
 	if err := g.currentState.Render(backBuffer); err != nil {
 		return err
 	}
 	return nil
 }
 
+// SetScreenshotScale sets the scale used when a screenshot is taken with the screenshot key.
+func (g *Game) SetScreenshotScale(scale int) {
+	g.screenshotScale = scale
+}
+
+// SetRecorder sets the recorder that is started and stopped with the record key.
+func (g *Game) SetRecorder(rec *platform.Recorder) {
+	g.recorder = rec
+}
+
+func (g *Game) toggleRecording() {
+	rec := g.recorder
+	if rec == nil {
+		return
+	}
+
+	if rec.Recording() {
+		if name, err := rec.Stop(); err != nil {
+			log.Println("Could not save recording:", err)
+		} else {
+			log.Println("Saved recording:", name)
+		}
+	} else if err := rec.Start(); err != nil {
+		log.Println("Could not start recording:", err)
+	} else {
+		log.Println("Recording started")
+	}
+}
+
+// Screenshot saves the last rendered frame. With a scale above one the current state is
+// rendered again at that many times the backbuffer resolution and the result is saved instead.
+func (g *Game) Screenshot(scale int) (string, error) {
+	if scale < 1 {
+		return "", fmt.Errorf("invalid screenshot scale: %d", scale)
+	}
+	if g.backBuffer == nil {
+		return "", errors.New("no frame has been rendered")
+	}
+
+	bounds := g.backBuffer.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, bounds.Dx()*scale, bounds.Dy()*scale))
+
+	if scale > 1 {
+		if err := g.currentState.Render(img); err != nil {
+			return "", err
+		}
+	} else {
+		draw.Draw(img, img.Bounds(), g.backBuffer, bounds.Min, draw.Src)
+	}
+
+	return platform.SaveScreenshot(img)
+}
+
 func (g *Game) Shutdown() {
 }
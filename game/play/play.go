@@ -22,12 +22,13 @@ import (
 	"image/color"
 	"image/draw"
 	"log"
+	"math"
+	"time"
 
 	"github.com/andreas-jonsson/go-wolf/engine"
 	"github.com/andreas-jonsson/go-wolf/game"
 	"github.com/andreas-jonsson/go-wolf/platform"
 	"github.com/andreas-jonsson/go-wolf/world"
-	"github.com/ungerik/go3d/float64/vec2"
 )
 
 type renderTarget struct {
@@ -66,41 +67,176 @@ func (rt *renderTarget) setBackBuffer(img draw.Image) {
 }
 
 type playState struct {
+	w  *world.World
 	rt *renderTarget
 	rc *engine.Raycaster
 	sc *engine.Spritecaster
+
+	// next returns the level that follows when the player reaches the exit.
+	next func() (*world.World, engine.SpriteInstances, error)
+
+	// level is reloaded when the assets change, if it was loaded by name. Errors from
+	// scanning the assets are shown until a scan succeeds.
+	level     string
+	watcher   *world.AssetWatcher
+	reloadErr error
+	watchErr  error
 }
 
-func NewPlayState() *playState {
+func NewPlayState(pixelAspect float64) *playState {
 	const level = "level1"
 
-	rt := new(renderTarget)
-	w, err := world.NewWorld(level)
+	lvl, err := world.LoadLevel(level)
 	if err != nil {
 		log.Panicln(err)
 	}
 
-	sprites, err := world.LoadSprites(level)
-	if err != nil {
+	rt := new(renderTarget)
+	s := &playState{
+		rt:      rt,
+		rc:      engine.NewRaycaster(rt, nil),
+		level:   level,
+		watcher: world.NewAssetWatcher(time.Second),
+	}
+	s.rc.SetPixelAspect(pixelAspect)
+
+	if err := s.loadLevel(lvl); err != nil {
 		log.Panicln(err)
 	}
+	return s
+}
+
+// SetWorld replaces the level that is played. The player is moved to its start when the state is entered.
+func (s *playState) SetWorld(w *world.World, sprites engine.SpriteInstances) {
+	s.level = ""
+	s.reloadErr, s.watchErr = nil, nil
+	s.setWorld(w, sprites)
+}
+
+// SetNextLevel sets a function that makes the level that follows the current one.
+// Without it, reaching the exit does nothing.
+func (s *playState) SetNextLevel(next func() (*world.World, engine.SpriteInstances, error)) {
+	s.next = next
+}
+
+func (s *playState) setWorld(w *world.World, sprites engine.SpriteInstances) {
+	s.w = w
+	s.rc.SetWorld(w)
+	s.sc = engine.NewSpritecaster(sprites)
+
+	if n := world.EvictUnusedAssets(); n > 0 {
+		images, bytes := world.AssetCacheStats()
+		log.Printf("Evicted %d assets, %d images (%d KiB) still cached", n, images, bytes/1024)
+	}
+}
+
+// loadLevel builds the world and sprites of lvl, without moving the camera.
+func (s *playState) loadLevel(lvl *world.Level) error {
+	w, err := world.NewWorldFromLevel(lvl)
+	if err != nil {
+		return err
+	}
+
+	sprites, err := world.LoadLevelSprites(lvl)
+	if err != nil {
+		return err
+	}
+
+	s.setWorld(w, sprites)
+	return nil
+}
+
+// reload loads the level again and keeps the camera where it is. Doors that are
+// still doors in the new level stay open. Errors are shown on screen until a
+// reload succeeds.
+func (s *playState) reload() {
+	openDoors := s.w.OpenDoors()
 
-	return &playState{
-		rt: rt,
-		rc: engine.NewRaycaster(rt, w),
-		sc: engine.NewSpritecaster(sprites),
+	lvl, err := world.LoadLevel(s.level)
+	if err == nil {
+		err = s.loadLevel(lvl)
 	}
+
+	if err != nil {
+		s.reloadFailed(err)
+		return
+	}
+
+	for _, pos := range openDoors {
+		s.w.OpenDoor(pos[0], pos[1])
+	}
+
+	s.reloadErr = nil
+	log.Println("Reloaded level:", s.level)
+}
+
+func (s *playState) reloadFailed(err error) {
+	log.Println("Could not reload level:", err)
+	s.reloadErr = err
 }
 
 func (s *playState) Name() string {
 	return "play"
 }
 
+// Enter moves the player to the start, unless it comes back from the editor with the
+// edited world, which is played from where the player was.
 func (s *playState) Enter(from game.GameState, args ...interface{}) error {
-	s.rc.Move(vec2.T{22.0, 11.5})
+	if len(args) > 0 {
+		if w, ok := args[0].(*world.World); ok {
+			sprites, err := w.Sprites()
+			if err != nil {
+				return err
+			}
+			s.setWorld(w, sprites)
+			return nil
+		}
+	}
+
+	s.moveToStart()
 	return nil
 }
 
+func (s *playState) moveToStart() {
+	pos, angle := s.w.Start()
+	s.rc.SetPos(pos)
+	s.rc.SetAngle(angle)
+}
+
+// checkExit moves on to the next level when the player stands on the exit,
+// or on a tile that triggers it.
+func (s *playState) checkExit() error {
+	pos := s.rc.Pos()
+	px, py := int(pos[0]), int(pos[1])
+
+	x, y, ok := s.w.Exit()
+	onExit := (ok && px == x && py == y) || s.w.TileDef(px, py).Triggers&world.TriggerExit != 0
+	if !onExit || s.next == nil {
+		return nil
+	}
+
+	w, sprites, err := s.next()
+	if err != nil {
+		return err
+	}
+
+	s.SetWorld(w, sprites)
+	s.moveToStart()
+	log.Println("Entered level:", w.Name())
+	return nil
+}
+
+// useDoor opens or closes the door in front of the player.
+func (s *playState) useDoor() {
+	pos, dir := s.rc.Pos(), s.rc.Dir()
+	x, y := int(math.Floor(pos[0]+dir[0])), int(math.Floor(pos[1]+dir[1]))
+	if s.w.DoorOpen(x, y) {
+		s.w.CloseDoor(x, y)
+	} else {
+		s.w.OpenDoor(x, y)
+	}
+}
+
 func (s *playState) Exit(to game.GameState) error {
 	return nil
 }
@@ -115,6 +251,18 @@ func (s *playState) Update(gctl game.GameControl) error {
 		rotSpeed  = 7.5
 	)
 
+	if s.level != "" {
+		changed, err := s.watcher.Poll()
+		if err != nil && s.watchErr == nil {
+			log.Println("Could not scan assets:", err)
+		}
+		s.watchErr = err
+
+		if len(changed) > 0 {
+			s.reload()
+		}
+	}
+
 	for event := gctl.PollEvent(); event != nil; event = gctl.PollEvent() {
 		switch t := event.(type) {
 		case *platform.QuitEvent:
@@ -124,28 +272,36 @@ func (s *playState) Update(gctl game.GameControl) error {
 			case platform.KeyUp:
 				v := rc.Dir()
 				v.Scale(moveSpeed * dtf)
-				rc.Move(v)
+				rc.SetPos(s.w.Move(rc.Pos(), v, world.PlayerRadius))
 			case platform.KeyDown:
 				v := rc.Dir()
 				v.Scale(-moveSpeed * dtf)
-				rc.Move(v)
+				rc.SetPos(s.w.Move(rc.Pos(), v, world.PlayerRadius))
 			case platform.KeyLeft:
 				rc.Rotate(rotSpeed * dtf)
 			case platform.KeyRight:
 				rc.Rotate(-rotSpeed * dtf)
 			}
+
+			if t.Rune == ' ' {
+				s.useDoor()
+			}
+			if (t.Rune == 'e' || t.Rune == 'E') && s.level != "" {
+				return gctl.SwitchState("edit", s.level, s.w)
+			}
 		}
 	}
-	return nil
+	return s.checkExit()
 }
 
 func (s *playState) Render(backBuffer draw.Image) error {
-	if s.rt.backBuffer == nil {
+	if s.rt.backBuffer != backBuffer {
 		s.rt.setBackBuffer(backBuffer)
 	}
 
 	size := backBuffer.Bounds().Size()
-	roofColor := color.RGBA{75, 75, 75, 255}
+	sky := s.w.Sky().Color
+	roofColor := color.RGBA{sky[0], sky[1], sky[2], 255}
 	floorColor := color.RGBA{100, 100, 100, 255}
 
 	for y := 0; y < size.Y; y++ {
@@ -162,5 +318,24 @@ func (s *playState) Render(backBuffer draw.Image) error {
 	rc.Render()
 	s.sc.Render(rc)
 
+	if s.reloadErr != nil {
+		s.renderError(backBuffer, s.reloadErr)
+	} else if s.watchErr != nil {
+		s.renderError(backBuffer, s.watchErr)
+	}
 	return nil
 }
+
+func (s *playState) renderError(backBuffer draw.Image, err error) {
+	const margin = 2
+
+	bounds := backBuffer.Bounds()
+	lines := game.WrapText(err.Error(), bounds.Dx()-margin*2)
+
+	box := image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Min.Y+len(lines)*game.GlyphHeight+margin*2)
+	draw.Draw(backBuffer, box, image.NewUniform(color.RGBA{128, 0, 0, 255}), image.Point{}, draw.Src)
+
+	for i, line := range lines {
+		game.DrawText(backBuffer, box.Min.X+margin, box.Min.Y+margin+i*game.GlyphHeight, line, color.White)
+	}
+}
@@ -25,6 +25,8 @@ const (
 	KeyRight
 	KeyEsc
 	KeyReturn
+	KeyF11
+	KeyF12
 )
 
 type (
@@ -37,4 +39,16 @@ type (
 	}
 
 	KeyDownEvent KeyUpEvent
+
+	// Mouse positions are in backbuffer pixels.
+	MouseMotionEvent struct {
+		X, Y int
+	}
+
+	MouseButtonUpEvent struct {
+		Button int
+		X, Y   int
+	}
+
+	MouseButtonDownEvent MouseButtonUpEvent
 )
@@ -18,17 +18,57 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package platform
 
 import (
+	"io"
 	"log"
 	"math"
+	"os"
+	"os/user"
 	"path"
+	"runtime"
 	"sync/atomic"
 )
 
 var (
-	ConfigPath string
-	idCounter  uint64
+	ConfigPath  string
+	idCounter   uint64
+	eventSource EventSource
 )
 
+type EventSource interface {
+	PollEvent() Event
+}
+
+func init() {
+	if runtime.GOOS == "windows" {
+		ConfigPath = path.Join(os.Getenv("LOCALAPPDATA"), "go-wolf")
+	} else {
+		if usr, err := user.Current(); err == nil {
+			ConfigPath = path.Join(usr.HomeDir, ".config", "go-wolf")
+		}
+	}
+
+	ConfigPath = path.Clean(ConfigPath)
+	os.MkdirAll(ConfigPath, 0755)
+}
+
+// SetEventSource replaces where PollEvent reads events from.
+// The previous source is closed if it implements io.Closer.
+func SetEventSource(src EventSource) {
+	if c, ok := eventSource.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			log.Println(err)
+		}
+	}
+	eventSource = src
+}
+
+func PollEvent() Event {
+	if eventSource == nil {
+		return nil
+	}
+	return eventSource.PollEvent()
+}
+
 func CfgRootJoin(p ...string) string {
 	return path.Clean(path.Join(ConfigPath, path.Join(p...)))
 }
@@ -0,0 +1,50 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package platform
+
+import "sync"
+
+// HeadlessEventSource is an event queue that is only fed by Push. Use it with
+// SetEventSource to drive the game without a window.
+type HeadlessEventSource struct {
+	lock   sync.Mutex
+	events []Event
+}
+
+func NewHeadlessEventSource() *HeadlessEventSource {
+	return &HeadlessEventSource{}
+}
+
+func (s *HeadlessEventSource) Push(events ...Event) {
+	s.lock.Lock()
+	s.events = append(s.events, events...)
+	s.lock.Unlock()
+}
+
+func (s *HeadlessEventSource) PollEvent() Event {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.events) == 0 {
+		return nil
+	}
+
+	ev := s.events[0]
+	s.events = s.events[1:]
+	return ev
+}
@@ -0,0 +1,35 @@
+//go:build nosdl
+
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package platform
+
+import "errors"
+
+var errNoSDL = errors.New("built without SDL support")
+
+func Init() error {
+	return errNoSDL
+}
+
+func Shutdown() {
+}
+
+func NewRenderer(configs ...Config) (Renderer, error) {
+	return nil, errNoSDL
+}
@@ -1,3 +1,5 @@
+//go:build !nosdl
+
 /*
 Copyright (C) 2017 Andreas T Jonsson
 
@@ -18,9 +20,7 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package platform
 
 import (
-	"os"
-	"os/user"
-	"path"
+	"image"
 	"runtime"
 
 	"github.com/veandco/go-sdl2/sdl"
@@ -33,21 +33,20 @@ var keyMapping = map[sdl.Keycode]int{
 	sdl.K_RIGHT:  KeyRight,
 	sdl.K_ESCAPE: KeyEsc,
 	sdl.K_RETURN: KeyReturn,
+	sdl.K_F11:    KeyF11,
+	sdl.K_F12:    KeyF12,
 }
 
-func init() {
-	runtime.LockOSThread()
+type sdlEventSource struct{}
 
-	if runtime.GOOS == "windows" {
-		ConfigPath = path.Join(os.Getenv("LOCALAPPDATA"), "go-wolf")
-	} else {
-		if usr, err := user.Current(); err == nil {
-			ConfigPath = path.Join(usr.HomeDir, ".config", "go-wolf")
-		}
-	}
+// sdlView is where the renderer last drew the backbuffer, used to map mouse positions.
+var sdlView struct {
+	dst image.Rectangle
+	res image.Point
+}
 
-	ConfigPath = path.Clean(ConfigPath)
-	os.MkdirAll(ConfigPath, 0755)
+func init() {
+	runtime.LockOSThread()
 }
 
 func Init() error {
@@ -55,6 +54,8 @@ func Init() error {
 	if err := sdl.Init(sdl.INIT_VIDEO | sdl.INIT_AUDIO | sdl.INIT_GAMECONTROLLER); err != nil {
 		return err
 	}
+
+	SetEventSource(sdlEventSource{})
 	return nil
 }
 
@@ -62,7 +63,7 @@ func Shutdown() {
 	sdl.Quit()
 }
 
-func PollEvent() Event {
+func (sdlEventSource) PollEvent() Event {
 	event := sdl.PollEvent()
 	if event == nil {
 		return nil
@@ -72,24 +73,43 @@ func PollEvent() Event {
 	case *sdl.QuitEvent:
 		return &QuitEvent{}
 	case *sdl.KeyUpEvent:
-		ev := &KeyUpEvent{}
-		if key, ok := keyMapping[t.Keysym.Sym]; ok {
-			ev.Key = key
-			ev.Rune = rune(t.Keysym.Unicode)
-		} else {
-			ev.Key = KeyUnknown
-		}
-		return ev
+		ev := KeyUpEvent(sdlKey(t.Keysym))
+		return &ev
 	case *sdl.KeyDownEvent:
-		ev := &KeyDownEvent{}
-		if key, ok := keyMapping[t.Keysym.Sym]; ok {
-			ev.Key = key
-			ev.Rune = rune(t.Keysym.Unicode)
-		} else {
-			ev.Key = KeyUnknown
+		ev := KeyDownEvent(sdlKey(t.Keysym))
+		return &ev
+	case *sdl.MouseMotionEvent:
+		x, y := sdlMousePos(t.X, t.Y)
+		return &MouseMotionEvent{X: x, Y: y}
+	case *sdl.MouseButtonEvent:
+		x, y := sdlMousePos(t.X, t.Y)
+		ev := MouseButtonUpEvent{Button: int(t.Button), X: x, Y: y}
+		if t.State == sdl.PRESSED {
+			down := MouseButtonDownEvent(ev)
+			return &down
 		}
-		return ev
+		return &ev
 	}
 
 	return nil
 }
+
+func sdlKey(sym sdl.Keysym) KeyUpEvent {
+	if key, ok := keyMapping[sym.Sym]; ok {
+		return KeyUpEvent{Key: key, Rune: rune(sym.Unicode)}
+	}
+
+	// Keycodes of printable keys are the characters.
+	if sym.Sym >= 0x20 && sym.Sym <= 0x7e {
+		return KeyUpEvent{Key: KeyUnknown, Rune: rune(sym.Sym)}
+	}
+	return KeyUpEvent{Key: KeyUnknown}
+}
+
+func sdlMousePos(x, y int32) (int, int) {
+	v := &sdlView
+	if v.dst.Empty() {
+		return int(x), int(y)
+	}
+	return (int(x) - v.dst.Min.X) * v.res.X / v.dst.Dx(), (int(y) - v.dst.Min.Y) * v.res.Y / v.dst.Dy()
+}
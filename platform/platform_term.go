@@ -0,0 +1,149 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package platform
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+)
+
+var termSequences = map[string]int{
+	"\x1b[A":   KeyUp,
+	"\x1b[B":   KeyDown,
+	"\x1b[C":   KeyRight,
+	"\x1b[D":   KeyLeft,
+	"\x1bOA":   KeyUp,
+	"\x1bOB":   KeyDown,
+	"\x1bOC":   KeyRight,
+	"\x1bOD":   KeyLeft,
+	"\x1b[23~": KeyF11,
+	"\x1b[24~": KeyF12,
+}
+
+// TerminalEventSource reads keyboard input from a terminal in raw mode.
+// Terminals only report key presses, so every key gives a KeyDownEvent directly followed by a KeyUpEvent.
+type TerminalEventSource struct {
+	events chan Event
+	state  string
+}
+
+func NewTerminalEventSource() (*TerminalEventSource, error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+
+	s := &TerminalEventSource{
+		events: make(chan Event, 64),
+		state:  strings.TrimSpace(state),
+	}
+
+	go s.readInput(os.Stdin)
+	return s, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func (s *TerminalEventSource) PollEvent() Event {
+	select {
+	case ev := <-s.events:
+		return ev
+	default:
+		return nil
+	}
+}
+
+// Close restores the terminal settings.
+func (s *TerminalEventSource) Close() error {
+	_, err := stty(s.state)
+	return err
+}
+
+func (s *TerminalEventSource) readInput(r io.Reader) {
+	buf := make([]byte, 256)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			s.events <- &QuitEvent{}
+			return
+		}
+
+		for _, ev := range parseTermInput(buf[:n]) {
+			s.events <- ev
+		}
+	}
+}
+
+func parseTermInput(b []byte) []Event {
+	var events []Event
+	keyPress := func(key int, r rune) {
+		events = append(events, &KeyDownEvent{Key: key, Rune: r}, &KeyUpEvent{Key: key, Rune: r})
+	}
+
+next:
+	for len(b) > 0 {
+		for seq, key := range termSequences {
+			if bytes.HasPrefix(b, []byte(seq)) {
+				keyPress(key, 0)
+				b = b[len(seq):]
+				continue next
+			}
+		}
+
+		// Skip escape sequences we do not know about, so they are not mistaken for an escape key.
+		if len(b) > 2 && b[0] == 0x1b && (b[1] == '[' || b[1] == 'O') {
+			i := 2
+			for i < len(b) && (b[i] < 0x40 || b[i] > 0x7e) {
+				i++
+			}
+			if i < len(b) {
+				i++
+			}
+			b = b[i:]
+			continue
+		}
+
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+
+		switch r {
+		case 0x03: // Ctrl-C
+			events = append(events, &QuitEvent{})
+		case 0x1b:
+			keyPress(KeyEsc, 0)
+		case '\r', '\n':
+			keyPress(KeyReturn, r)
+		default:
+			keyPress(KeyUnknown, r)
+		}
+	}
+	return events
+}
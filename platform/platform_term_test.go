@@ -0,0 +1,61 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package platform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTermInput(t *testing.T) {
+	press := func(key int, r rune) []Event {
+		return []Event{&KeyDownEvent{Key: key, Rune: r}, &KeyUpEvent{Key: key, Rune: r}}
+	}
+	join := func(events ...[]Event) []Event {
+		var all []Event
+		for _, e := range events {
+			all = append(all, e...)
+		}
+		return all
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []Event
+	}{
+		{"CSI arrows", "\x1b[A\x1b[B\x1b[C\x1b[D", join(press(KeyUp, 0), press(KeyDown, 0), press(KeyRight, 0), press(KeyLeft, 0))},
+		{"SS3 arrows", "\x1bOA\x1bOB\x1bOC\x1bOD", join(press(KeyUp, 0), press(KeyDown, 0), press(KeyRight, 0), press(KeyLeft, 0))},
+		{"F11 and F12", "\x1b[23~\x1b[24~", join(press(KeyF11, 0), press(KeyF12, 0))},
+		{"unknown sequences", "\x1b[15~a\x1b[1;5Cb\x1bOPc", join(press(KeyUnknown, 'a'), press(KeyUnknown, 'b'), press(KeyUnknown, 'c'))},
+		{"unterminated sequence", "\x1b[12", nil},
+		{"lone escape", "\x1b", press(KeyEsc, 0)},
+		{"escape before a key", "\x1bq", join(press(KeyEsc, 0), press(KeyUnknown, 'q'))},
+		{"escape and bracket", "\x1b[", join(press(KeyEsc, 0), press(KeyUnknown, '['))},
+		{"return", "\r\n", join(press(KeyReturn, '\r'), press(KeyReturn, '\n'))},
+		{"text", "wä", join(press(KeyUnknown, 'w'), press(KeyUnknown, 'ä'))},
+		{"ctrl-c", "w\x03", join(press(KeyUnknown, 'w'), []Event{&QuitEvent{}})},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		if got := parseTermInput([]byte(tt.input)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
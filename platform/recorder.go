@@ -0,0 +1,229 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"log"
+	"os"
+	"path"
+	"time"
+)
+
+type RecordFormat int
+
+const (
+	RecordGIF RecordFormat = iota
+	RecordPNG
+)
+
+func ParseRecordFormat(s string) (RecordFormat, error) {
+	switch s {
+	case "gif":
+		return RecordGIF, nil
+	case "png":
+		return RecordPNG, nil
+	}
+	return RecordGIF, fmt.Errorf("invalid record format: %s", s)
+}
+
+// Recorder wraps a Renderer and captures the backbuffer on Present while recording.
+// Frames are captured at a fixed rate regardless of how fast the game renders.
+type Recorder struct {
+	Renderer
+
+	format   RecordFormat
+	interval time.Duration
+	palette  color.Palette
+
+	recording bool
+	name      string
+	start     time.Time
+	numFrames int
+	// lastStart is the frame the last GIF image is shown from.
+	lastStart int
+	anim      *gif.GIF
+}
+
+func NewRecorder(rnd Renderer, format RecordFormat, fps int) *Recorder {
+	if fps <= 0 {
+		fps = 15
+	}
+	return &Recorder{
+		Renderer: rnd,
+		format:   format,
+		interval: time.Second / time.Duration(fps),
+	}
+}
+
+func (r *Recorder) SetPalette(pal color.Palette) {
+	r.palette = pal
+	r.Renderer.SetPalette(pal)
+}
+
+func (r *Recorder) Recording() bool {
+	return r.recording
+}
+
+// Start begins a new recording in the recordings folder under ConfigPath.
+func (r *Recorder) Start() error {
+	if r.recording {
+		return errors.New("already recording")
+	}
+
+	dir := CfgRootJoin("recordings")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	stamp := time.Now().Format("go-wolf-20060102-150405")
+	switch r.format {
+	case RecordGIF:
+		r.name = CfgRootJoin("recordings", stamp+".gif")
+		r.anim = &gif.GIF{}
+	case RecordPNG:
+		r.name = CfgRootJoin("recordings", stamp)
+		if err := os.MkdirAll(r.name, 0755); err != nil {
+			return err
+		}
+	}
+
+	r.recording = true
+	r.start = time.Now()
+	r.numFrames = 0
+	return nil
+}
+
+// Stop ends the recording and returns the name of the GIF file or PNG folder.
+func (r *Recorder) Stop() (string, error) {
+	if !r.recording {
+		return "", errors.New("not recording")
+	}
+
+	r.recording = false
+	if r.format != RecordGIF {
+		return r.name, nil
+	}
+
+	anim := r.anim
+	r.anim = nil
+
+	if len(anim.Image) == 0 {
+		return "", errors.New("no frames recorded")
+	}
+
+	fp, err := os.Create(r.name)
+	if err != nil {
+		return "", err
+	}
+
+	if err := gif.EncodeAll(fp, anim); err != nil {
+		fp.Close()
+		return "", err
+	}
+	return r.name, fp.Close()
+}
+
+func (r *Recorder) Present() {
+	if r.recording {
+		if err := r.capture(); err != nil {
+			log.Println("Recording stopped:", err)
+			r.recording = false
+		}
+	}
+	r.Renderer.Present()
+}
+
+func (r *Recorder) capture() error {
+	// Number of frames the recording should have by now.
+	n := int(time.Since(r.start)/r.interval) + 1
+	if n <= r.numFrames {
+		return nil
+	}
+
+	img := r.BackBuffer()
+	switch r.format {
+	case RecordGIF:
+		// Frames we missed are covered by holding the previous one longer.
+		if last := len(r.anim.Delay) - 1; last >= 0 {
+			r.anim.Delay[last] = r.delay(r.lastStart, n-1)
+		}
+		r.anim.Image = append(r.anim.Image, r.paletted(img))
+		r.anim.Delay = append(r.anim.Delay, r.delay(n-1, n))
+		r.lastStart = n - 1
+		r.numFrames = n
+	case RecordPNG:
+		// Frames we missed are filled with copies so the sequence keeps its frame rate.
+		for ; r.numFrames < n; r.numFrames++ {
+			if err := r.writePNG(img); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// delay returns the time from frame from to frame to in the 100ths of a second GIF uses.
+// Frame times are rounded from the start of the recording, so the rounding errors do
+// not add up and the recording keeps its speed.
+func (r *Recorder) delay(from, to int) int {
+	hundredths := func(frame int) int {
+		t := time.Duration(frame) * r.interval
+		return int((t + 5*time.Millisecond) / (10 * time.Millisecond))
+	}
+	return hundredths(to) - hundredths(from)
+}
+
+func (r *Recorder) paletted(img image.Image) *image.Paletted {
+	bounds := img.Bounds()
+	if p, ok := img.(*image.Paletted); ok {
+		dst := image.NewPaletted(bounds, p.Palette)
+		copy(dst.Pix, p.Pix)
+		return dst
+	}
+
+	if r.palette != nil {
+		dst := image.NewPaletted(bounds, r.palette)
+		draw.Draw(dst, bounds, img, bounds.Min, draw.Src)
+		return dst
+	}
+
+	dst := image.NewPaletted(bounds, palette.Plan9)
+	draw.FloydSteinberg.Draw(dst, bounds, img, bounds.Min)
+	return dst
+}
+
+func (r *Recorder) writePNG(img image.Image) error {
+	fp, err := os.Create(path.Join(r.name, fmt.Sprintf("frame%05d.png", r.numFrames)))
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(fp, img); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
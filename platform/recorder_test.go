@@ -0,0 +1,41 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package platform
+
+import "testing"
+
+func TestRecorderDelay(t *testing.T) {
+	for _, fps := range []int{15, 24, 30, 60} {
+		r := NewRecorder(nil, RecordGIF, fps)
+
+		// One frame at a time, and frames held for 1 to 4 frames.
+		for _, step := range []int{1, 2, 3, 4} {
+			total, end := 0, fps*10
+			for f := 0; f < end; f += step {
+				to := f + step
+				if to > end {
+					to = end
+				}
+				total += r.delay(f, to)
+			}
+			if total != 1000 {
+				t.Errorf("%d fps, %d frames per image: 10 seconds last %d hundredths", fps, step, total)
+			}
+		}
+	}
+}
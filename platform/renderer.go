@@ -20,14 +20,106 @@ package platform
 import (
 	"image"
 	"image/color"
+	"image/draw"
 )
 
 type Renderer interface {
 	Clear()
 	Present()
 	Shutdown()
-	BackBuffer() *image.Paletted
+	BackBuffer() draw.Image
 	SetPalette(pal color.Palette)
+	PixelAspect() float64
 	ToggleFullscreen()
 	SetWindowTitle(title string)
 }
+
+type Config func(*rendererConfig) error
+
+type rendererConfig struct {
+	windowTitle   string
+	windowSize    image.Point
+	resolution    image.Point
+	resolutionDiv int
+	scaleMode     ScaleMode
+	frameDump     string
+	debug, novsync,
+	fullscreen, squarePixels bool
+}
+
+func newRendererConfig(configs []Config) (rendererConfig, error) {
+	var rc rendererConfig
+	for _, cfg := range configs {
+		if err := cfg(&rc); err != nil {
+			return rc, err
+		}
+	}
+
+	if rc.resolution.X <= 0 || rc.resolution.Y <= 0 {
+		rc.resolution = image.Point{320, 200}
+	}
+	return rc, nil
+}
+
+func ConfigWithSize(w, h int) Config {
+	return func(rc *rendererConfig) error {
+		rc.windowSize = image.Point{w, h}
+		return nil
+	}
+}
+
+func ConfigWithTitle(title string) Config {
+	return func(rc *rendererConfig) error {
+		rc.windowTitle = title
+		return nil
+	}
+}
+
+func ConfigWithDiv(n int) Config {
+	return func(rc *rendererConfig) error {
+		rc.resolutionDiv = n
+		return nil
+	}
+}
+
+func ConfigWithResolution(w, h int) Config {
+	return func(rc *rendererConfig) error {
+		rc.resolution = image.Point{w, h}
+		return nil
+	}
+}
+
+func ConfigWithScaleMode(mode ScaleMode) Config {
+	return func(rc *rendererConfig) error {
+		rc.scaleMode = mode
+		return nil
+	}
+}
+
+// ConfigWithFrameDump makes renderers without a display write every presented frame as a PNG to dir.
+func ConfigWithFrameDump(dir string) Config {
+	return func(rc *rendererConfig) error {
+		rc.frameDump = dir
+		return nil
+	}
+}
+
+func ConfigWithSquarePixels(rc *rendererConfig) error {
+	rc.squarePixels = true
+	return nil
+}
+
+func ConfigWithFullscreen(rc *rendererConfig) error {
+	rc.fullscreen = true
+	return nil
+}
+
+func ConfigWithDebug(rc *rendererConfig) error {
+	rc.debug = true
+	return nil
+}
+
+func ConfigWithNoVSync(rc *rendererConfig) error {
+	rc.novsync = true
+	return nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package platform
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"os"
+	"path"
+)
+
+type headlessRenderer struct {
+	backBuffer *image.RGBA
+	palette    color.Palette
+	title      string
+	numFrames  int
+	config     rendererConfig
+}
+
+// NewHeadlessRenderer creates a renderer that keeps the backbuffer in memory and never opens a window.
+func NewHeadlessRenderer(configs ...Config) (*headlessRenderer, error) {
+	var (
+		err error
+		r   headlessRenderer
+	)
+
+	if r.config, err = newRendererConfig(configs); err != nil {
+		return nil, err
+	}
+
+	if r.config.frameDump != "" {
+		if err := os.MkdirAll(r.config.frameDump, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	r.title = r.config.windowTitle
+	r.backBuffer = image.NewRGBA(image.Rectangle{Max: r.config.resolution})
+	return &r, nil
+}
+
+func (r *headlessRenderer) ToggleFullscreen() {
+}
+
+func (r *headlessRenderer) PixelAspect() float64 {
+	return pixelAspect(r.config.resolution, r.config.squarePixels)
+}
+
+func (r *headlessRenderer) BackBuffer() draw.Image {
+	return r.backBuffer
+}
+
+func (r *headlessRenderer) SetPalette(pal color.Palette) {
+	r.palette = pal
+}
+
+func (r *headlessRenderer) Clear() {
+	pix := r.backBuffer.Pix
+	for i := range pix {
+		pix[i] = 0
+	}
+}
+
+func (r *headlessRenderer) Present() {
+	if r.config.frameDump != "" {
+		if err := r.dumpFrame(); err != nil {
+			log.Panicln(err)
+		}
+	}
+	r.numFrames++
+}
+
+func (r *headlessRenderer) dumpFrame() error {
+	fp, err := os.Create(path.Join(r.config.frameDump, fmt.Sprintf("frame%05d.png", r.numFrames)))
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	return png.Encode(fp, r.backBuffer)
+}
+
+// NumFrames returns how many frames have been presented.
+func (r *headlessRenderer) NumFrames() int {
+	return r.numFrames
+}
+
+func (r *headlessRenderer) Shutdown() {
+}
+
+func (r *headlessRenderer) SetWindowTitle(title string) {
+	r.title = title
+}
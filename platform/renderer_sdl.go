@@ -1,3 +1,5 @@
+//go:build !nosdl
+
 /*
 Copyright (C) 2017 Andreas T Jonsson
 
@@ -19,6 +21,7 @@ package platform
 
 import (
 	"image"
+	"image/color"
 	"image/draw"
 	"log"
 	"reflect"
@@ -29,57 +32,13 @@ import (
 
 const fullscreenFlag = sdl.WINDOW_FULLSCREEN //sdl.WINDOW_FULLSCREEN_DESKTOP
 
-type Config func(*sdlRenderer) error
-
-func ConfigWithSize(w, h int) Config {
-	return func(rnd *sdlRenderer) error {
-		rnd.config.windowSize = image.Point{w, h}
-		return nil
-	}
-}
-
-func ConfigWithTitle(title string) Config {
-	return func(rnd *sdlRenderer) error {
-		rnd.config.windowTitle = title
-		return nil
-	}
-}
-
-func ConfigWithDiv(n int) Config {
-	return func(rnd *sdlRenderer) error {
-		rnd.config.resolutionDiv = n
-		return nil
-	}
-}
-
-func ConfigWithFullscreen(rnd *sdlRenderer) error {
-	rnd.config.fullscreen = true
-	return nil
-}
-
-func ConfigWithDebug(rnd *sdlRenderer) error {
-	rnd.config.debug = true
-	return nil
-}
-
-func ConfigWithNoVSync(rnd *sdlRenderer) error {
-	rnd.config.novsync = true
-	return nil
-}
-
 type sdlRenderer struct {
 	window           *sdl.Window
 	backBuffer       *image.RGBA
 	hwBuffer         *sdl.Texture
 	internalRenderer *sdl.Renderer
-
-	config struct {
-		windowTitle   string
-		windowSize    image.Point
-		resolutionDiv int
-		debug, novsync,
-		fullscreen bool
-	}
+	palette          color.Palette
+	config           rendererConfig
 }
 
 func NewRenderer(configs ...Config) (*sdlRenderer, error) {
@@ -91,10 +50,8 @@ func NewRenderer(configs ...Config) (*sdlRenderer, error) {
 		sdlFlags uint32 = sdl.WINDOW_SHOWN
 	)
 
-	for _, cfg := range configs {
-		if err = cfg(&r); err != nil {
-			return nil, err
-		}
+	if r.config, err = newRendererConfig(configs); err != nil {
+		return nil, err
 	}
 
 	cfg := &r.config
@@ -123,7 +80,7 @@ func NewRenderer(configs ...Config) (*sdlRenderer, error) {
 		return nil, err
 	}
 
-	const width, height = 320, 200
+	width, height := cfg.resolution.X, cfg.resolution.Y
 	r.backBuffer = image.NewRGBA(image.Rect(0, 0, width, height))
 
 	renderer, err := sdl.CreateRenderer(r.window, -1, sdl.RENDERER_ACCELERATED)
@@ -131,7 +88,6 @@ func NewRenderer(configs ...Config) (*sdlRenderer, error) {
 		return nil, err
 	}
 
-	renderer.SetLogicalSize(width, height)
 	r.internalRenderer = renderer
 
 	r.hwBuffer, err = renderer.CreateTexture(sdl.PIXELFORMAT_ABGR8888, sdl.TEXTUREACCESS_STREAMING, width, height)
@@ -152,10 +108,18 @@ func (r *sdlRenderer) ToggleFullscreen() {
 	}
 }
 
+func (r *sdlRenderer) PixelAspect() float64 {
+	return pixelAspect(r.config.resolution, r.config.squarePixels)
+}
+
 func (r *sdlRenderer) BackBuffer() draw.Image {
 	return r.backBuffer
 }
 
+func (r *sdlRenderer) SetPalette(pal color.Palette) {
+	r.palette = pal
+}
+
 func (r *sdlRenderer) Clear() {
 	pix := r.backBuffer.Pix
 	for i := range pix {
@@ -179,7 +143,17 @@ func (r *sdlRenderer) Present() {
 	copy(dest, r.backBuffer.Pix)
 
 	r.hwBuffer.Unlock()
-	r.internalRenderer.Copy(r.hwBuffer, nil, nil)
+
+	outW, outH, err := r.internalRenderer.GetRendererOutputSize()
+	if err != nil {
+		log.Panicln(err)
+	}
+
+	dst := scaleRect(r.config.scaleMode, r.config.resolution, r.PixelAspect(), image.Point{outW, outH})
+	sdlView.dst, sdlView.res = dst, r.config.resolution
+	r.internalRenderer.SetDrawColor(0, 0, 0, 255)
+	r.internalRenderer.Clear()
+	r.internalRenderer.Copy(r.hwBuffer, nil, &sdl.Rect{int32(dst.Min.X), int32(dst.Min.Y), int32(dst.Dx()), int32(dst.Dy())})
 	r.internalRenderer.Present()
 }
 
@@ -0,0 +1,173 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package platform
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	termFrameTime   = time.Second / 30
+	termSizeRefresh = time.Second
+)
+
+// terminalRenderer draws the backbuffer with 24-bit ANSI colors, using the upper half block
+// character so every character cell shows two pixels.
+type terminalRenderer struct {
+	backBuffer *image.RGBA
+	palette    color.Palette
+	out        *bufio.Writer
+	title      string
+
+	termSize            image.Point
+	lastDraw, lastQuery time.Time
+	config              rendererConfig
+}
+
+func NewTerminalRenderer(configs ...Config) (*terminalRenderer, error) {
+	var (
+		err error
+		r   terminalRenderer
+	)
+
+	if r.config, err = newRendererConfig(configs); err != nil {
+		return nil, err
+	}
+
+	r.backBuffer = image.NewRGBA(image.Rectangle{Max: r.config.resolution})
+	r.out = bufio.NewWriterSize(os.Stdout, 1<<16)
+	r.updateTermSize()
+
+	// Switch to the alternate screen and hide the cursor.
+	io.WriteString(r.out, "\x1b[?1049h\x1b[?25l\x1b[2J")
+	r.SetWindowTitle(r.config.windowTitle)
+	return &r, r.out.Flush()
+}
+
+// updateTermSize asks the terminal for its size in characters. The configured window
+// size is used when the output is not a terminal.
+func (r *terminalRenderer) updateTermSize() {
+	r.lastQuery = time.Now()
+	r.termSize = r.config.windowSize
+
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	if out, err := cmd.Output(); err == nil {
+		fmt.Sscan(strings.TrimSpace(string(out)), &r.termSize.Y, &r.termSize.X)
+	}
+
+	if r.termSize.X <= 0 || r.termSize.Y <= 0 {
+		r.termSize = image.Point{80, 25}
+	}
+}
+
+func (r *terminalRenderer) ToggleFullscreen() {
+}
+
+func (r *terminalRenderer) PixelAspect() float64 {
+	return pixelAspect(r.config.resolution, r.config.squarePixels)
+}
+
+func (r *terminalRenderer) BackBuffer() draw.Image {
+	return r.backBuffer
+}
+
+func (r *terminalRenderer) SetPalette(pal color.Palette) {
+	r.palette = pal
+}
+
+func (r *terminalRenderer) Clear() {
+	pix := r.backBuffer.Pix
+	for i := range pix {
+		pix[i] = 0
+	}
+}
+
+func (r *terminalRenderer) Present() {
+	// Terminals are slow, so frames that come too fast are dropped.
+	if time.Since(r.lastDraw) < termFrameTime {
+		return
+	}
+	r.lastDraw = time.Now()
+
+	if time.Since(r.lastQuery) >= termSizeRefresh {
+		r.updateTermSize()
+	}
+
+	// Leave the last line free so the terminal does not scroll.
+	out := image.Point{r.termSize.X, (r.termSize.Y - 1) * 2}
+	dst := scaleRect(r.config.scaleMode, r.config.resolution, r.PixelAspect(), out)
+
+	var (
+		w          = r.out
+		src        = r.backBuffer
+		srcSize    = src.Bounds().Size()
+		noColor    = color.RGBA{1, 2, 3, 0} // Never sampled, forces a color code at the start of a line.
+		background = color.RGBA{0, 0, 0, 255}
+	)
+
+	sample := func(x, y int) color.RGBA {
+		p := image.Point{x, y}
+		if !p.In(dst) {
+			return background
+		}
+		p = p.Sub(dst.Min)
+		return src.RGBAAt(p.X*srcSize.X/dst.Dx(), p.Y*srcSize.Y/dst.Dy())
+	}
+
+	io.WriteString(w, "\x1b[H")
+	for y := 0; y < out.Y; y += 2 {
+		lastFg, lastBg := noColor, noColor
+		for x := 0; x < out.X; x++ {
+			fg, bg := sample(x, y), sample(x, y+1)
+			if fg != lastFg {
+				fmt.Fprintf(w, "\x1b[38;2;%d;%d;%dm", fg.R, fg.G, fg.B)
+				lastFg = fg
+			}
+			if bg != lastBg {
+				fmt.Fprintf(w, "\x1b[48;2;%d;%d;%dm", bg.R, bg.G, bg.B)
+				lastBg = bg
+			}
+			io.WriteString(w, "▀")
+		}
+		io.WriteString(w, "\x1b[0m\r\n")
+	}
+	w.Flush()
+}
+
+func (r *terminalRenderer) Shutdown() {
+	io.WriteString(r.out, "\x1b[0m\x1b[?25h\x1b[?1049l")
+	r.out.Flush()
+}
+
+func (r *terminalRenderer) SetWindowTitle(title string) {
+	if title == r.title {
+		return
+	}
+	r.title = title
+	fmt.Fprintf(r.out, "\x1b]0;%s\x07", title)
+}
@@ -0,0 +1,541 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package platform
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"log"
+	"net"
+	"sync"
+)
+
+// Reference: https://github.com/rfbproto/rfbproto/blob/master/rfbproto.rst
+
+const (
+	rfbVersion = "RFB 003.008\n"
+
+	rfbSecurityNone = 1
+	rfbEncodingRaw  = 0
+
+	rfbSetPixelFormat           = 0
+	rfbSetEncodings             = 2
+	rfbFramebufferUpdateRequest = 3
+	rfbKeyEvent                 = 4
+	rfbPointerEvent             = 5
+	rfbClientCutText            = 6
+)
+
+var vncKeyMapping = map[uint32]int{
+	0xff52: KeyUp,
+	0xff54: KeyDown,
+	0xff51: KeyLeft,
+	0xff53: KeyRight,
+	0xff1b: KeyEsc,
+	0xff0d: KeyReturn,
+	0xff8d: KeyReturn,
+	0xffc8: KeyF11,
+	0xffc9: KeyF12,
+}
+
+type rfbPixelFormat struct {
+	BitsPerPixel, Depth, BigEndian, TrueColor uint8
+	RedMax, GreenMax, BlueMax                 uint16
+	RedShift, GreenShift, BlueShift           uint8
+	_                                         [3]byte
+}
+
+var rfbDefaultPixelFormat = rfbPixelFormat{
+	BitsPerPixel: 32,
+	Depth:        24,
+	TrueColor:    1,
+	RedMax:       255,
+	GreenMax:     255,
+	BlueMax:      255,
+	RedShift:     16,
+	GreenShift:   8,
+	BlueShift:    0,
+}
+
+// vncRenderer serves the backbuffer to VNC clients and turns their input into events.
+// It implements EventSource, so it can be passed to SetEventSource.
+type vncRenderer struct {
+	backBuffer *image.RGBA
+	palette    color.Palette
+	listener   net.Listener
+	events     chan Event
+	done       chan struct{}
+
+	lock    sync.Mutex
+	frame   *image.RGBA
+	frameId uint64
+	title   string
+	clients map[*vncClient]struct{}
+
+	config rendererConfig
+}
+
+type vncClient struct {
+	server *vncRenderer
+	conn   net.Conn
+	wake   chan struct{}
+
+	lock        sync.Mutex
+	format      rfbPixelFormat
+	requested   bool
+	incremental bool
+	sentId      uint64
+	buttons     uint8
+	pointer     image.Point
+}
+
+// NewVNCRenderer starts an RFB server on addr, for example "localhost:5900".
+func NewVNCRenderer(addr string, configs ...Config) (*vncRenderer, error) {
+	var (
+		err error
+		r   vncRenderer
+	)
+
+	if r.config, err = newRendererConfig(configs); err != nil {
+		return nil, err
+	}
+
+	r.title = r.config.windowTitle
+	r.backBuffer = image.NewRGBA(image.Rectangle{Max: r.config.resolution})
+	r.frame = image.NewRGBA(r.backBuffer.Bounds())
+	r.events = make(chan Event, 256)
+	r.done = make(chan struct{})
+	r.clients = make(map[*vncClient]struct{})
+
+	if r.listener, err = net.Listen("tcp", addr); err != nil {
+		return nil, err
+	}
+
+	go r.serve()
+	return &r, nil
+}
+
+// Addr returns the address the server is listening on.
+func (r *vncRenderer) Addr() net.Addr {
+	return r.listener.Addr()
+}
+
+func (r *vncRenderer) serve() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &vncClient{
+			server: r,
+			conn:   conn,
+			wake:   make(chan struct{}, 1),
+			format: rfbDefaultPixelFormat,
+		}
+		go c.run()
+	}
+}
+
+func (r *vncRenderer) PollEvent() Event {
+	select {
+	case ev := <-r.events:
+		return ev
+	default:
+		return nil
+	}
+}
+
+func (r *vncRenderer) pushEvent(ev Event) bool {
+	select {
+	case r.events <- ev:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+func (r *vncRenderer) ToggleFullscreen() {
+}
+
+func (r *vncRenderer) PixelAspect() float64 {
+	return pixelAspect(r.config.resolution, r.config.squarePixels)
+}
+
+func (r *vncRenderer) BackBuffer() draw.Image {
+	return r.backBuffer
+}
+
+func (r *vncRenderer) SetPalette(pal color.Palette) {
+	r.palette = pal
+}
+
+func (r *vncRenderer) Clear() {
+	pix := r.backBuffer.Pix
+	for i := range pix {
+		pix[i] = 0
+	}
+}
+
+func (r *vncRenderer) Present() {
+	r.lock.Lock()
+	copy(r.frame.Pix, r.backBuffer.Pix)
+	r.frameId++
+
+	for c := range r.clients {
+		c.notify()
+	}
+	r.lock.Unlock()
+}
+
+func (r *vncRenderer) Shutdown() {
+	close(r.done)
+	r.listener.Close()
+
+	r.lock.Lock()
+	for c := range r.clients {
+		c.conn.Close()
+	}
+	r.lock.Unlock()
+}
+
+func (r *vncRenderer) SetWindowTitle(title string) {
+	r.lock.Lock()
+	r.title = title
+	r.lock.Unlock()
+}
+
+func (c *vncClient) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (c *vncClient) run() {
+	defer c.conn.Close()
+
+	rd := bufio.NewReader(c.conn)
+	if err := c.handshake(rd); err != nil {
+		log.Println("VNC:", err)
+		return
+	}
+
+	r := c.server
+	r.lock.Lock()
+	r.clients[c] = struct{}{}
+	r.lock.Unlock()
+
+	defer func() {
+		r.lock.Lock()
+		delete(r.clients, c)
+		r.lock.Unlock()
+		close(c.wake)
+	}()
+
+	go c.writeUpdates()
+
+	if err := c.readMessages(rd); err != nil && err != io.EOF {
+		select {
+		case <-r.done:
+		default:
+			log.Println("VNC:", err)
+		}
+	}
+}
+
+func (c *vncClient) handshake(rd io.Reader) error {
+	if _, err := io.WriteString(c.conn, rfbVersion); err != nil {
+		return err
+	}
+
+	var version [12]byte
+	if _, err := io.ReadFull(rd, version[:]); err != nil {
+		return err
+	}
+
+	var major, minor int
+	if _, err := fmt.Sscanf(string(version[:]), "RFB %03d.%03d\n", &major, &minor); err != nil || major != 3 {
+		return fmt.Errorf("unsupported protocol version: %q", version)
+	}
+
+	if minor < 7 {
+		// Version 3.3 lets the server decide the security type.
+		if err := binary.Write(c.conn, binary.BigEndian, uint32(rfbSecurityNone)); err != nil {
+			return err
+		}
+	} else {
+		if _, err := c.conn.Write([]byte{1, rfbSecurityNone}); err != nil {
+			return err
+		}
+
+		var secType [1]byte
+		if _, err := io.ReadFull(rd, secType[:]); err != nil {
+			return err
+		}
+		if secType[0] != rfbSecurityNone {
+			return fmt.Errorf("unsupported security type: %d", secType[0])
+		}
+
+		if minor >= 8 {
+			if err := binary.Write(c.conn, binary.BigEndian, uint32(0)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// ClientInit only holds the shared flag, and we always share.
+	var shared [1]byte
+	if _, err := io.ReadFull(rd, shared[:]); err != nil {
+		return err
+	}
+
+	r := c.server
+	r.lock.Lock()
+	name := r.title
+	r.lock.Unlock()
+
+	size := r.backBuffer.Bounds().Size()
+	serverInit := struct {
+		Width, Height uint16
+		Format        rfbPixelFormat
+		NameLength    uint32
+	}{uint16(size.X), uint16(size.Y), rfbDefaultPixelFormat, uint32(len(name))}
+
+	if err := binary.Write(c.conn, binary.BigEndian, &serverInit); err != nil {
+		return err
+	}
+	_, err := io.WriteString(c.conn, name)
+	return err
+}
+
+func (c *vncClient) readMessages(rd io.Reader) error {
+	var msgType [1]byte
+	for {
+		if _, err := io.ReadFull(rd, msgType[:]); err != nil {
+			return err
+		}
+
+		switch msgType[0] {
+		case rfbSetPixelFormat:
+			var msg struct {
+				_      [3]byte
+				Format rfbPixelFormat
+			}
+			if err := binary.Read(rd, binary.BigEndian, &msg); err != nil {
+				return err
+			}
+			if msg.Format.TrueColor == 0 {
+				return errors.New("color map pixel formats are not supported")
+			}
+			switch msg.Format.BitsPerPixel {
+			case 8, 16, 32:
+			default:
+				return fmt.Errorf("unsupported bits per pixel: %d", msg.Format.BitsPerPixel)
+			}
+
+			c.lock.Lock()
+			c.format = msg.Format
+			c.lock.Unlock()
+		case rfbSetEncodings:
+			var msg struct {
+				_            byte
+				NumEncodings uint16
+			}
+			if err := binary.Read(rd, binary.BigEndian, &msg); err != nil {
+				return err
+			}
+
+			// Raw is always supported, so the list does not matter.
+			encodings := make([]int32, msg.NumEncodings)
+			if err := binary.Read(rd, binary.BigEndian, encodings); err != nil {
+				return err
+			}
+		case rfbFramebufferUpdateRequest:
+			var msg struct {
+				Incremental         uint8
+				X, Y, Width, Height uint16
+			}
+			if err := binary.Read(rd, binary.BigEndian, &msg); err != nil {
+				return err
+			}
+
+			c.lock.Lock()
+			c.requested = true
+			c.incremental = msg.Incremental != 0
+			c.lock.Unlock()
+			c.notify()
+		case rfbKeyEvent:
+			var msg struct {
+				Down uint8
+				_    [2]byte
+				Key  uint32
+			}
+			if err := binary.Read(rd, binary.BigEndian, &msg); err != nil {
+				return err
+			}
+			if !c.server.pushEvent(keyEvent(msg.Key, msg.Down != 0)) {
+				return nil
+			}
+		case rfbPointerEvent:
+			var msg struct {
+				Buttons uint8
+				X, Y    uint16
+			}
+			if err := binary.Read(rd, binary.BigEndian, &msg); err != nil {
+				return err
+			}
+			for _, ev := range c.pointerEvents(msg.Buttons, image.Point{int(msg.X), int(msg.Y)}) {
+				if !c.server.pushEvent(ev) {
+					return nil
+				}
+			}
+		case rfbClientCutText:
+			var msg struct {
+				_      [3]byte
+				Length uint32
+			}
+			if err := binary.Read(rd, binary.BigEndian, &msg); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(io.Discard, rd, int64(msg.Length)); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown client message: %d", msgType[0])
+		}
+	}
+}
+
+func keyEvent(keysym uint32, down bool) Event {
+	ev := KeyUpEvent{Key: KeyUnknown}
+	if key, ok := vncKeyMapping[keysym]; ok {
+		ev.Key = key
+	} else if keysym >= 0x20 && keysym <= 0x7e {
+		// Latin-1 keysyms are the same as the characters.
+		ev.Rune = rune(keysym)
+	}
+
+	if down {
+		down := KeyDownEvent(ev)
+		return &down
+	}
+	return &ev
+}
+
+func (c *vncClient) pointerEvents(buttons uint8, pos image.Point) []Event {
+	var events []Event
+	if pos != c.pointer {
+		events = append(events, &MouseMotionEvent{X: pos.X, Y: pos.Y})
+		c.pointer = pos
+	}
+
+	for i := uint(0); i < 8; i++ {
+		mask := uint8(1) << i
+		if (c.buttons^buttons)&mask == 0 {
+			continue
+		}
+
+		ev := MouseButtonUpEvent{Button: int(i) + 1, X: pos.X, Y: pos.Y}
+		if buttons&mask != 0 {
+			down := MouseButtonDownEvent(ev)
+			events = append(events, &down)
+		} else {
+			events = append(events, &ev)
+		}
+	}
+
+	c.buttons = buttons
+	return events
+}
+
+func (c *vncClient) writeUpdates() {
+	var (
+		r     = c.server
+		frame = image.NewRGBA(r.frame.Bounds())
+		buf   []byte
+	)
+
+	for range c.wake {
+		r.lock.Lock()
+		id := r.frameId
+
+		c.lock.Lock()
+		send := c.requested && (!c.incremental || id > c.sentId)
+		if send {
+			c.requested = false
+			c.sentId = id
+			copy(frame.Pix, r.frame.Pix)
+		}
+		format := c.format
+		c.lock.Unlock()
+		r.lock.Unlock()
+
+		if send {
+			buf = encodeRawUpdate(buf[:0], frame, &format)
+			if _, err := c.conn.Write(buf); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func encodeRawUpdate(buf []byte, img *image.RGBA, format *rfbPixelFormat) []byte {
+	size := img.Bounds().Size()
+	header := [16]byte{0, 0, 0, 1}
+	binary.BigEndian.PutUint16(header[8:], uint16(size.X))
+	binary.BigEndian.PutUint16(header[10:], uint16(size.Y))
+	binary.BigEndian.PutUint32(header[12:], rfbEncodingRaw)
+	buf = append(buf, header[:]...)
+
+	var (
+		order         binary.ByteOrder = binary.LittleEndian
+		bytesPerPixel                  = int(format.BitsPerPixel / 8)
+		pixel         [4]byte
+	)
+
+	if format.BigEndian != 0 {
+		order = binary.BigEndian
+	}
+
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			c := img.RGBAAt(x, y)
+			v := uint32(c.R)*uint32(format.RedMax)/255<<format.RedShift |
+				uint32(c.G)*uint32(format.GreenMax)/255<<format.GreenShift |
+				uint32(c.B)*uint32(format.BlueMax)/255<<format.BlueShift
+
+			switch bytesPerPixel {
+			case 1:
+				pixel[0] = uint8(v)
+			case 2:
+				order.PutUint16(pixel[:], uint16(v))
+			case 4:
+				order.PutUint32(pixel[:], v)
+			}
+			buf = append(buf, pixel[:bytesPerPixel]...)
+		}
+	}
+	return buf
+}
@@ -0,0 +1,217 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package platform
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type rfbTestClient struct {
+	t    *testing.T
+	conn net.Conn
+	size image.Point
+}
+
+func dialVNC(t *testing.T, r *vncRenderer) *rfbTestClient {
+	conn, err := net.Dial("tcp", r.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	c := &rfbTestClient{t: t, conn: conn}
+
+	var version [12]byte
+	c.read(&version)
+	if string(version[:]) != rfbVersion {
+		t.Fatalf("unexpected version: %q", version)
+	}
+	c.write([]byte(rfbVersion))
+
+	var secTypes [2]byte
+	c.read(&secTypes)
+	if secTypes != [2]byte{1, rfbSecurityNone} {
+		t.Fatalf("unexpected security types: %v", secTypes)
+	}
+	c.write([]byte{rfbSecurityNone})
+
+	var secResult uint32
+	if c.read(&secResult); secResult != 0 {
+		t.Fatalf("security handshake failed: %d", secResult)
+	}
+
+	c.write([]byte{1})
+
+	var serverInit struct {
+		Width, Height uint16
+		Format        rfbPixelFormat
+		NameLength    uint32
+	}
+	c.read(&serverInit)
+	c.size = image.Point{int(serverInit.Width), int(serverInit.Height)}
+
+	name := make([]byte, serverInit.NameLength)
+	c.read(name)
+	return c
+}
+
+func (c *rfbTestClient) read(data interface{}) {
+	if err := binary.Read(c.conn, binary.BigEndian, data); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *rfbTestClient) write(data interface{}) {
+	if err := binary.Write(c.conn, binary.BigEndian, data); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *rfbTestClient) requestUpdate(incremental bool) []byte {
+	var inc uint8
+	if incremental {
+		inc = 1
+	}
+	c.write([]byte{rfbFramebufferUpdateRequest, inc})
+	c.write([]uint16{0, 0, uint16(c.size.X), uint16(c.size.Y)})
+
+	var header struct {
+		Type     uint8
+		_        uint8
+		NumRects uint16
+		X, Y     uint16
+		W, H     uint16
+		Encoding int32
+	}
+	c.read(&header)
+
+	if header.NumRects != 1 || header.Encoding != rfbEncodingRaw || int(header.W) != c.size.X || int(header.H) != c.size.Y {
+		c.t.Fatalf("unexpected update header: %+v", header)
+	}
+
+	pix := make([]byte, c.size.X*c.size.Y*4)
+	if _, err := io.ReadFull(c.conn, pix); err != nil {
+		c.t.Fatal(err)
+	}
+	return pix
+}
+
+func waitEvents(t *testing.T, r *vncRenderer, n int) []Event {
+	var events []Event
+	deadline := time.Now().Add(5 * time.Second)
+
+	for len(events) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for events, got %d of %d", len(events), n)
+		}
+		if ev := r.PollEvent(); ev != nil {
+			events = append(events, ev)
+		} else {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	return events
+}
+
+func TestVNCFramebufferUpdate(t *testing.T) {
+	r, err := NewVNCRenderer("localhost:0", ConfigWithResolution(32, 20), ConfigWithTitle("go-wolf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Shutdown()
+
+	c := dialVNC(t, r)
+	defer c.conn.Close()
+
+	if c.size != (image.Point{32, 20}) {
+		t.Fatalf("unexpected framebuffer size: %v", c.size)
+	}
+
+	r.BackBuffer().Set(3, 2, color.RGBA{10, 20, 30, 255})
+	r.Present()
+
+	pix := c.requestUpdate(false)
+
+	// The default pixel format is little endian 0x00RRGGBB.
+	i := (2*c.size.X + 3) * 4
+	if got := pix[i : i+4]; !reflect.DeepEqual(got, []byte{30, 20, 10, 0}) {
+		t.Errorf("unexpected pixel: %v", got)
+	}
+
+	// An incremental request is answered when the next frame is presented.
+	r.BackBuffer().Set(0, 0, color.RGBA{255, 0, 0, 255})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		r.Present()
+	}()
+
+	pix = c.requestUpdate(true)
+	if got := pix[:4]; !reflect.DeepEqual(got, []byte{0, 0, 255, 0}) {
+		t.Errorf("unexpected pixel after incremental update: %v", got)
+	}
+}
+
+func TestVNCInputEvents(t *testing.T) {
+	r, err := NewVNCRenderer("localhost:0", ConfigWithResolution(32, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Shutdown()
+
+	c := dialVNC(t, r)
+	defer c.conn.Close()
+
+	keyMsg := func(down uint8, key uint32) {
+		c.write([]byte{rfbKeyEvent, down, 0, 0})
+		c.write(key)
+	}
+
+	keyMsg(1, 0xff52)
+	keyMsg(0, 0xff52)
+	keyMsg(1, 'a')
+
+	c.write([]byte{rfbPointerEvent, 0})
+	c.write([]uint16{5, 6})
+	c.write([]byte{rfbPointerEvent, 1})
+	c.write([]uint16{5, 6})
+	c.write([]byte{rfbPointerEvent, 0})
+	c.write([]uint16{5, 6})
+
+	expected := []Event{
+		&KeyDownEvent{Key: KeyUp},
+		&KeyUpEvent{Key: KeyUp},
+		&KeyDownEvent{Key: KeyUnknown, Rune: 'a'},
+		&MouseMotionEvent{X: 5, Y: 6},
+		&MouseButtonDownEvent{Button: 1, X: 5, Y: 6},
+		&MouseButtonUpEvent{Button: 1, X: 5, Y: 6},
+	}
+
+	events := waitEvents(t, r, len(expected))
+	for i, ev := range events {
+		if !reflect.DeepEqual(ev, expected[i]) {
+			t.Errorf("event %d: got %#v, expected %#v", i, ev, expected[i])
+		}
+	}
+}
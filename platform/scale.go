@@ -0,0 +1,79 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package platform
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+type ScaleMode int
+
+const (
+	ScaleFit ScaleMode = iota
+	ScaleInteger
+	ScaleStretch
+)
+
+var scaleModeNames = map[string]ScaleMode{
+	"fit":     ScaleFit,
+	"integer": ScaleInteger,
+	"stretch": ScaleStretch,
+}
+
+func ParseScaleMode(s string) (ScaleMode, error) {
+	if m, ok := scaleModeNames[s]; ok {
+		return m, nil
+	}
+	return ScaleFit, fmt.Errorf("invalid scale mode: %s", s)
+}
+
+func ParseResolution(s string) (image.Point, error) {
+	var p image.Point
+	if _, err := fmt.Sscanf(s, "%dx%d", &p.X, &p.Y); err != nil || p.X <= 0 || p.Y <= 0 {
+		return p, fmt.Errorf("invalid resolution: %s", s)
+	}
+	return p, nil
+}
+
+// pixelAspect returns the width/height ratio of one backbuffer pixel on the display.
+// 200-line modes were shown on 4:3 monitors, so their pixels are taller than wide.
+func pixelAspect(res image.Point, squarePixels bool) float64 {
+	if !squarePixels && res.Y%200 == 0 {
+		return 5.0 / 6.0
+	}
+	return 1
+}
+
+// scaleRect returns where a backbuffer of size res should be drawn on an output of size out.
+func scaleRect(mode ScaleMode, res image.Point, aspect float64, out image.Point) image.Rectangle {
+	if mode == ScaleStretch {
+		return image.Rectangle{Max: out}
+	}
+
+	lw, lh := float64(res.X), float64(res.Y)/aspect
+	s := math.Min(float64(out.X)/lw, float64(out.Y)/lh)
+	if mode == ScaleInteger && s >= 1 {
+		s = math.Floor(s)
+	}
+
+	size := image.Point{int(lw*s + 0.5), int(lh*s + 0.5)}
+	min := out.Sub(size).Div(2)
+	return image.Rectangle{min, min.Add(size)}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package platform
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"time"
+)
+
+// SaveScreenshot writes img as a timestamped PNG in the screenshots folder under ConfigPath
+// and returns the name of the file.
+func SaveScreenshot(img image.Image) (string, error) {
+	dir := CfgRootJoin("screenshots")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	name := CfgRootJoin("screenshots", time.Now().Format("go-wolf-20060102-150405.000.png"))
+	fp, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+
+	if err := png.Encode(fp, img); err != nil {
+		fp.Close()
+		return "", err
+	}
+	return name, fp.Close()
+}
@@ -0,0 +1,144 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Assets are read from this file system with paths like "maps/level1.json".
+// It defaults to the data directory in the working directory.
+var assets fs.FS = os.DirFS("data")
+
+// SetAssets replaces the file system assets are loaded from and empties the asset
+// cache. The previous file system is closed if it is an io.Closer.
+func SetAssets(fsys fs.FS) {
+	if c, ok := assets.(io.Closer); ok {
+		c.Close()
+	}
+	assets = fsys
+	clearAssetCache()
+}
+
+func Assets() fs.FS {
+	return assets
+}
+
+// OpenAssets opens a directory or a zip archive asset pack.
+func OpenAssets(name string) (fs.FS, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		return os.DirFS(name), nil
+	}
+
+	if !strings.EqualFold(filepath.Ext(name), ".zip") {
+		return nil, errors.New("asset pack is not a directory or zip archive: " + name)
+	}
+	return zip.OpenReader(name)
+}
+
+// SearchPath looks up files in a list of file systems. The first one that
+// has a file wins, so mods placed first can override single files.
+type SearchPath []fs.FS
+
+func (sp SearchPath) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	for _, fsys := range sp {
+		fp, err := fsys.Open(name)
+		if err == nil {
+			return fp, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir merges the directory from every file system in the search path.
+func (sp SearchPath) ReadDir(name string) ([]fs.DirEntry, error) {
+	var (
+		entries []fs.DirEntry
+		found   bool
+		seen    = make(map[string]bool)
+	)
+
+	for _, fsys := range sp {
+		list, err := fs.ReadDir(fsys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		found = true
+		for _, e := range list {
+			if !seen[e.Name()] {
+				seen[e.Name()] = true
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// Close closes every file system in the search path that is an io.Closer.
+func (sp SearchPath) Close() error {
+	var err error
+	for _, fsys := range sp {
+		if c, ok := fsys.(io.Closer); ok {
+			if e := c.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// OpenSearchPath opens each directory or zip archive in names with OpenAssets.
+func OpenSearchPath(names []string) (SearchPath, error) {
+	var sp SearchPath
+	for _, name := range names {
+		fsys, err := OpenAssets(name)
+		if err != nil {
+			sp.Close()
+			return nil, err
+		}
+		sp = append(sp, fsys)
+	}
+	return sp, nil
+}
@@ -0,0 +1,191 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world_test
+
+import (
+	"archive/zip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+)
+
+type closerFS struct {
+	fstest.MapFS
+	closed int
+	err    error
+}
+
+func (c *closerFS) Close() error {
+	c.closed++
+	return c.err
+}
+
+func readString(t *testing.T, fsys fs.FS, name string) string {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSearchPath(t *testing.T) {
+	sp := world.SearchPath{
+		fstest.MapFS{
+			"maps/level1.json": {Data: []byte("mod")},
+			"maps/mod.json":    {Data: []byte("mod")},
+		},
+		fstest.MapFS{
+			"maps/level1.json":       {Data: []byte("base")},
+			"maps/level2.json":       {Data: []byte("base")},
+			"textures/textures.json": {Data: []byte("base")},
+		},
+	}
+
+	for name, want := range map[string]string{
+		"maps/level1.json":       "mod",
+		"maps/mod.json":          "mod",
+		"maps/level2.json":       "base",
+		"textures/textures.json": "base",
+	} {
+		if got := readString(t, sp, name); got != want {
+			t.Errorf("%s: read from %s, want %s", name, got, want)
+		}
+	}
+
+	if _, err := sp.Open("maps/level3.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing file: got %v", err)
+	}
+	if _, err := sp.Open("../maps/level1.json"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("invalid path: got %v", err)
+	}
+
+	entries, err := fs.ReadDir(sp, "maps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"level1.json", "level2.json", "mod.json"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("merged maps directory is %v, want %v", names, want)
+	}
+
+	if files, err := fs.Glob(sp, "maps/*.json"); err != nil || len(files) != 3 {
+		t.Errorf("glob found %v, %v", files, err)
+	}
+	if _, err := fs.ReadDir(sp, "sprites"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing directory: got %v", err)
+	}
+}
+
+func TestSearchPathClose(t *testing.T) {
+	errClose := errors.New("close failed")
+	a, b := &closerFS{err: errClose}, &closerFS{err: errors.New("second")}
+	sp := world.SearchPath{a, fstest.MapFS{}, b}
+
+	if err := sp.Close(); err != errClose {
+		t.Errorf("got %v, want the first error", err)
+	}
+	if a.closed != 1 || b.closed != 1 {
+		t.Errorf("closed %d and %d times, want once each", a.closed, b.closed)
+	}
+
+	// SetAssets closes the file system it replaces.
+	old := world.Assets()
+	c := &closerFS{}
+	world.SetAssets(c)
+	world.SetAssets(old)
+	if c.closed != 1 {
+		t.Errorf("SetAssets closed the old assets %d times, want once", c.closed)
+	}
+}
+
+func writeZip(t *testing.T, name string, files map[string]string) {
+	fp, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+
+	zw := zip.NewWriter(fp)
+	for file, data := range files {
+		w, err := zw.Create(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenSearchPath(t *testing.T) {
+	tmp := t.TempDir()
+
+	base := filepath.Join(tmp, "data")
+	if err := os.MkdirAll(filepath.Join(base, "maps"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"level1.json": "base", "level2.json": "base"} {
+		if err := os.WriteFile(filepath.Join(base, "maps", name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mod := filepath.Join(tmp, "mod.ZIP")
+	writeZip(t, mod, map[string]string{"maps/level1.json": "mod", "maps/mod.json": "mod"})
+
+	notPack := filepath.Join(tmp, "mod.txt")
+	if err := os.WriteFile(notPack, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sp, err := world.OpenSearchPath([]string{mod, base})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, sp, "maps/level1.json"); got != "mod" {
+		t.Errorf("maps/level1.json read from %s, want the zip pack", got)
+	}
+	if got := readString(t, sp, "maps/level2.json"); got != "base" {
+		t.Errorf("maps/level2.json read from %s, want the directory", got)
+	}
+	if entries, err := fs.ReadDir(sp, "maps"); err != nil || len(entries) != 3 {
+		t.Errorf("merged maps directory is %v, %v", entries, err)
+	}
+	if err := sp.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+
+	for _, names := range [][]string{
+		{base, filepath.Join(tmp, "missing")},
+		{mod, notPack},
+	} {
+		if _, err := world.OpenSearchPath(names); err == nil {
+			t.Errorf("%v: expected an error", names)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+)
+
+// Windows bitmaps with 1, 4, 8, 16, 24 or 32 bits per pixel, uncompressed or with RLE or
+// bit field compression. Only the header sizes used by the common versions are read.
+
+// maxImagePixels limits the size of decoded BMP and PCX images, so a broken header can not
+// make the decoder allocate gigabytes.
+const maxImagePixels = 1 << 24
+
+const (
+	bmpRGB       = 0
+	bmpRLE8      = 1
+	bmpRLE4      = 2
+	bmpBitFields = 3
+)
+
+type bmpHeader struct {
+	width, height int
+	topDown       bool
+	bpp           int
+	compression   uint32
+	masks         [4]uint32
+	palette       color.Palette
+	dataOffset    int64
+}
+
+func init() {
+	image.RegisterFormat("bmp", "BM", decodeBMP, decodeBMPConfig)
+}
+
+func readBMPHeader(r io.Reader) (*bmpHeader, int64, error) {
+	var file struct {
+		Magic    [2]byte
+		Size     uint32
+		Reserved uint32
+		Offset   uint32
+		InfoSize uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &file); err != nil {
+		return nil, 0, err
+	}
+	if string(file.Magic[:]) != "BM" {
+		return nil, 0, errors.New("bmp: invalid signature")
+	}
+
+	h := &bmpHeader{dataOffset: int64(file.Offset)}
+	read := int64(18)
+
+	switch file.InfoSize {
+	case 12:
+		// OS/2 core header.
+		var core struct {
+			Width, Height uint16
+			Planes, BPP   uint16
+		}
+		if err := binary.Read(r, binary.LittleEndian, &core); err != nil {
+			return nil, 0, err
+		}
+		read += 8
+		h.width, h.height, h.bpp = int(core.Width), int(core.Height), int(core.BPP)
+	case 40, 52, 56, 108, 124:
+		var info struct {
+			Width, Height          int32
+			Planes, BPP            uint16
+			Compression, ImageSize uint32
+			XRes, YRes             int32
+			Colors, Important      uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &info); err != nil {
+			return nil, 0, err
+		}
+		read += 36
+
+		h.width, h.height, h.bpp, h.compression = int(info.Width), int(info.Height), int(info.BPP), info.Compression
+		if h.height < 0 {
+			h.height, h.topDown = -h.height, true
+		}
+
+		// The masks follow the header when it is too short to hold them.
+		numMasks := 0
+		if h.compression == bmpBitFields {
+			numMasks = 3
+		}
+		if file.InfoSize > 40 {
+			numMasks = int(file.InfoSize-40) / 4
+			if numMasks > 4 {
+				numMasks = 4
+			}
+		}
+		for i := 0; i < numMasks; i++ {
+			if err := binary.Read(r, binary.LittleEndian, &h.masks[i]); err != nil {
+				return nil, 0, err
+			}
+			read += 4
+		}
+		if file.InfoSize > 40 && file.InfoSize > uint32(40+numMasks*4) {
+			skip := int64(file.InfoSize) - 40 - int64(numMasks*4)
+			if _, err := io.CopyN(io.Discard, r, skip); err != nil {
+				return nil, 0, err
+			}
+			read += skip
+		}
+
+		if h.bpp <= 8 {
+			n := int(info.Colors)
+			if n == 0 || n > 1<<uint(h.bpp) {
+				n = 1 << uint(h.bpp)
+			}
+			pal := make([]byte, n*4)
+			if _, err := io.ReadFull(r, pal); err != nil {
+				return nil, 0, err
+			}
+			read += int64(len(pal))
+			for i := 0; i < n; i++ {
+				h.palette = append(h.palette, color.RGBA{pal[i*4+2], pal[i*4+1], pal[i*4], 255})
+			}
+		}
+	default:
+		return nil, 0, fmt.Errorf("bmp: unsupported header size %d", file.InfoSize)
+	}
+
+	if file.InfoSize == 12 && h.bpp <= 8 {
+		n := 1 << uint(h.bpp)
+		pal := make([]byte, n*3)
+		if _, err := io.ReadFull(r, pal); err != nil {
+			return nil, 0, err
+		}
+		read += int64(len(pal))
+		for i := 0; i < n; i++ {
+			h.palette = append(h.palette, color.RGBA{pal[i*3+2], pal[i*3+1], pal[i*3], 255})
+		}
+	}
+
+	if h.width <= 0 || h.height <= 0 {
+		return nil, 0, errors.New("bmp: invalid size")
+	}
+	if h.width*h.height > maxImagePixels {
+		return nil, 0, errors.New("bmp: image is too large")
+	}
+
+	switch h.bpp {
+	case 1, 4, 8, 24:
+	case 16, 32:
+		if h.compression == bmpRGB {
+			if h.bpp == 16 {
+				h.masks = [4]uint32{0x7c00, 0x03e0, 0x001f, 0}
+			} else {
+				h.masks = [4]uint32{0xff0000, 0x00ff00, 0x0000ff, 0}
+			}
+		}
+	default:
+		return nil, 0, fmt.Errorf("bmp: unsupported bit depth %d", h.bpp)
+	}
+
+	switch {
+	case h.compression == bmpRGB:
+	case h.compression == bmpRLE8 && h.bpp == 8:
+	case h.compression == bmpRLE4 && h.bpp == 4:
+	case h.compression == bmpBitFields && (h.bpp == 16 || h.bpp == 32):
+	default:
+		return nil, 0, fmt.Errorf("bmp: unsupported compression %d", h.compression)
+	}
+	return h, read, nil
+}
+
+func decodeBMPConfig(r io.Reader) (image.Config, error) {
+	h, _, err := readBMPHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	cfg := image.Config{Width: h.width, Height: h.height, ColorModel: color.RGBAModel}
+	if h.palette != nil {
+		cfg.ColorModel = h.palette
+	}
+	return cfg, nil
+}
+
+func decodeBMP(r io.Reader) (image.Image, error) {
+	h, read, err := readBMPHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.dataOffset > read {
+		if _, err := io.CopyN(io.Discard, r, h.dataOffset-read); err != nil {
+			return nil, err
+		}
+	}
+
+	// row returns the image row for the n:th row in the file.
+	row := func(n int) int {
+		if h.topDown {
+			return n
+		}
+		return h.height - 1 - n
+	}
+
+	if h.bpp <= 8 {
+		img := image.NewPaletted(image.Rect(0, 0, h.width, h.height), h.palette)
+		if h.compression == bmpRGB {
+			err = readBMPIndexed(r, h, img, row)
+		} else {
+			err = readBMPRLE(bufio.NewReader(r), h, img, row)
+		}
+
+		for i, c := range img.Pix {
+			if int(c) >= len(h.palette) {
+				img.Pix[i] = 0
+			}
+		}
+		return img, err
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, h.width, h.height))
+	stride := (h.width*h.bpp/8 + 3) &^ 3
+	buf := make([]byte, stride)
+
+	for n := 0; n < h.height; n++ {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+
+		pix := img.Pix[row(n)*img.Stride:]
+		for x := 0; x < h.width; x++ {
+			p := pix[x*4 : x*4+4]
+			switch h.bpp {
+			case 24:
+				p[0], p[1], p[2], p[3] = buf[x*3+2], buf[x*3+1], buf[x*3], 255
+			case 16:
+				bmpBitFieldPixel(p, uint32(binary.LittleEndian.Uint16(buf[x*2:])), &h.masks)
+			case 32:
+				bmpBitFieldPixel(p, binary.LittleEndian.Uint32(buf[x*4:]), &h.masks)
+			}
+		}
+	}
+	return img, nil
+}
+
+func bmpBitFieldPixel(p []byte, v uint32, masks *[4]uint32) {
+	for i, m := range masks {
+		if m == 0 {
+			// A missing color is black and a missing alpha is opaque.
+			p[i] = 0
+			if i == 3 {
+				p[i] = 255
+			}
+			continue
+		}
+
+		shift, size := bits.TrailingZeros32(m), bits.OnesCount32(m)
+		c := uint64(v&m) >> uint(shift)
+		p[i] = uint8(c * 255 / (1<<uint(size) - 1))
+	}
+}
+
+func readBMPIndexed(r io.Reader, h *bmpHeader, img *image.Paletted, row func(int) int) error {
+	stride := (h.width*h.bpp + 31) / 32 * 4
+	buf := make([]byte, stride)
+	perByte := 8 / h.bpp
+	mask := byte(1<<uint(h.bpp) - 1)
+
+	for n := 0; n < h.height; n++ {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return err
+		}
+
+		pix := img.Pix[row(n)*img.Stride:]
+		for x := 0; x < h.width; x++ {
+			shift := uint(8 - h.bpp*(x%perByte+1))
+			pix[x] = buf[x/perByte] >> shift & mask
+		}
+	}
+	return nil
+}
+
+func readBMPRLE(r io.ByteReader, h *bmpHeader, img *image.Paletted, row func(int) int) error {
+	set := func(x, n int, c byte) {
+		if x < h.width && n < h.height {
+			img.Pix[row(n)*img.Stride+x] = c
+		}
+	}
+
+	x, n := 0, 0
+	for n < h.height {
+		count, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		value, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			for i := 0; i < int(count); i++ {
+				c := value
+				if h.bpp == 4 {
+					c = value >> 4
+					if i%2 == 1 {
+						c = value & 0xf
+					}
+				}
+				set(x, n, c)
+				x++
+			}
+			continue
+		}
+
+		switch value {
+		case 0:
+			x, n = 0, n+1
+		case 1:
+			return nil
+		case 2:
+			dx, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			dy, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			x, n = x+int(dx), n+int(dy)
+		default:
+			// Absolute mode, padded to a whole word.
+			size := int(value)
+			if h.bpp == 4 {
+				size = (size + 1) / 2
+			}
+
+			var b byte
+			for i := 0; i < int(value); i++ {
+				if h.bpp == 8 || i%2 == 0 {
+					if b, err = r.ReadByte(); err != nil {
+						return err
+					}
+				}
+
+				c := b
+				if h.bpp == 4 {
+					c = b >> 4
+					if i%2 == 1 {
+						c = b & 0xf
+					}
+				}
+				set(x, n, c)
+				x++
+			}
+
+			if size%2 == 1 {
+				if _, err := r.ReadByte(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/png"
+	"strings"
+	"sync"
+)
+
+// The asset cache decodes every image once and shares it between sprite instances and levels.
+// Images are converted to RGBA, which the engine samples fastest.
+var cache = struct {
+	sync.Mutex
+	images     map[string]*cachedImage
+	generation int
+}{images: make(map[string]*cachedImage)}
+
+type cachedImage struct {
+	img        *image.RGBA
+	generation int
+	// files the image was made from.
+	files []string
+}
+
+// loadImage returns the image at name in the assets, decoding it if it is not cached.
+// A name like "sprites/guard.sheet.json#walk" is a frame in a sprite sheet.
+func loadImage(name string) (*image.RGBA, error) {
+	cache.Lock()
+	if c, ok := cache.images[name]; ok {
+		c.generation = cache.generation
+		cache.Unlock()
+		return c.img, nil
+	}
+	cache.Unlock()
+
+	var (
+		img   *image.RGBA
+		files = []string{name}
+		err   error
+	)
+
+	if i := strings.Index(name, "#"); i >= 0 {
+		img, files, err = loadFrame(name[:i], name[i+1:])
+	} else {
+		img, err = decodeImage(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cache.Lock()
+	cache.images[name] = &cachedImage{img, cache.generation, files}
+	cache.Unlock()
+	return img, nil
+}
+
+// decodeImage decodes an image in any registered format and converts it to RGBA.
+func decodeImage(name string) (*image.RGBA, error) {
+	fp, err := assets.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	img, _, err := image.Decode(fp)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	rgba, ok := img.(*image.RGBA)
+	if !ok || rgba.Bounds().Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rectangle{Max: img.Bounds().Size()})
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+	return rgba, nil
+}
+
+// EvictUnusedAssets drops the cached images that were not used since the last call.
+// Call it after switching to a new level. It returns the number of images evicted.
+func EvictUnusedAssets() int {
+	cache.Lock()
+	defer cache.Unlock()
+
+	n := 0
+	for name, c := range cache.images {
+		if c.generation != cache.generation {
+			delete(cache.images, name)
+			n++
+		}
+	}
+
+	cache.generation++
+	return n
+}
+
+// AssetCacheStats returns the number of cached images and the bytes used by their pixels.
+func AssetCacheStats() (images, bytes int) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	for _, c := range cache.images {
+		bytes += len(c.img.Pix)
+	}
+	return len(cache.images), bytes
+}
+
+func clearAssetCache() {
+	cache.Lock()
+	cache.images = make(map[string]*cachedImage)
+	cache.Unlock()
+}
+
+// forgetAssets drops the cached images made from any of the files in names.
+func forgetAssets(names []string) {
+	changed := make(map[string]bool)
+	for _, name := range names {
+		changed[name] = true
+	}
+
+	cache.Lock()
+	for name, c := range cache.images {
+		for _, f := range c.files {
+			if changed[f] {
+				delete(cache.images, name)
+				break
+			}
+		}
+	}
+	cache.Unlock()
+}
@@ -0,0 +1,357 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// ChunkCacheSize is the number of chunks of a chunked map that are kept in memory.
+// Chunks that have been changed are kept until the world is dropped.
+var ChunkCacheSize = 64
+
+// DefaultChunkSize is the chunk size SaveChunkedLevel uses when given zero.
+const DefaultChunkSize = 64
+
+// tileMap stores the tile layer of a world. Tiles outside the map read as 0 and
+// setting them does nothing.
+type tileMap interface {
+	size() (rows, cols int)
+	tile(x, y int) int
+	setTile(x, y, t int)
+}
+
+// gridMap keeps all tiles in memory.
+type gridMap [][]int
+
+func (m gridMap) size() (rows, cols int) {
+	if len(m) == 0 {
+		return 0, 0
+	}
+	return len(m), len(m[0])
+}
+
+func (m gridMap) inside(x, y int) bool {
+	return x >= 0 && x < len(m) && y >= 0 && y < len(m[x])
+}
+
+func (m gridMap) tile(x, y int) int {
+	if !m.inside(x, y) {
+		return 0
+	}
+	return m[x][y]
+}
+
+func (m gridMap) setTile(x, y, t int) {
+	if m.inside(x, y) {
+		m[x][y] = t
+	}
+}
+
+type chunk struct {
+	pos   [2]int
+	tiles []int
+	dirty bool
+}
+
+// chunkMap loads the chunks of a tile layer from the assets when they are first used
+// and drops the least recently used ones when more than ChunkCacheSize are loaded.
+// Chunk files hold the tiles as little endian 16 bit values, one chunk row after
+// the other. Chunks without a file are filled with the Fill tile.
+type chunkMap struct {
+	fsys   fs.FS
+	dir    string
+	hdr    Chunks
+	chunks map[[2]int]*list.Element
+	lru    *list.List
+	last   *chunk
+}
+
+func newChunkMap(fsys fs.FS, hdr Chunks) (*chunkMap, error) {
+	if hdr.Rows <= 0 || hdr.Cols <= 0 || hdr.Size <= 0 {
+		return nil, fmt.Errorf("invalid chunked map: %dx%d in chunks of %d", hdr.Rows, hdr.Cols, hdr.Size)
+	}
+
+	return &chunkMap{
+		fsys:   fsys,
+		dir:    path.Join("maps", hdr.Dir),
+		hdr:    hdr,
+		chunks: make(map[[2]int]*list.Element),
+		lru:    list.New(),
+	}, nil
+}
+
+func chunkFile(cx, cy int) string {
+	return fmt.Sprintf("%d_%d.bin", cx, cy)
+}
+
+func (m *chunkMap) size() (rows, cols int) {
+	return m.hdr.Rows, m.hdr.Cols
+}
+
+func (m *chunkMap) inside(x, y int) bool {
+	return x >= 0 && x < m.hdr.Rows && y >= 0 && y < m.hdr.Cols
+}
+
+func (m *chunkMap) tile(x, y int) int {
+	if !m.inside(x, y) {
+		return 0
+	}
+	s := m.hdr.Size
+	return m.chunk(x/s, y/s).tiles[x%s*s+y%s]
+}
+
+func (m *chunkMap) setTile(x, y, t int) {
+	if !m.inside(x, y) {
+		return
+	}
+	s := m.hdr.Size
+	c := m.chunk(x/s, y/s)
+	c.tiles[x%s*s+y%s] = t
+	c.dirty = true
+}
+
+// chunk returns the chunk at cx, cy, loading it if needed.
+func (m *chunkMap) chunk(cx, cy int) *chunk {
+	pos := [2]int{cx, cy}
+	if m.last != nil && m.last.pos == pos {
+		return m.last
+	}
+
+	if e, ok := m.chunks[pos]; ok {
+		m.lru.MoveToFront(e)
+		m.last = e.Value.(*chunk)
+		return m.last
+	}
+
+	c, err := m.load(cx, cy)
+	if err != nil {
+		log.Println("Could not load map chunk:", err)
+	}
+
+	m.chunks[pos] = m.lru.PushFront(c)
+	m.last = c
+	m.evict()
+	return c
+}
+
+// evict drops unchanged chunks, oldest first, until at most ChunkCacheSize are loaded.
+// The newest chunk is kept, since it is about to be used.
+func (m *chunkMap) evict() {
+	for e := m.lru.Back(); e != m.lru.Front() && m.lru.Len() > ChunkCacheSize; {
+		prev := e.Prev()
+		if c := e.Value.(*chunk); !c.dirty {
+			m.lru.Remove(e)
+			delete(m.chunks, c.pos)
+		}
+		e = prev
+	}
+}
+
+// load reads a chunk. The chunk is filled with the Fill tile if it has no file or
+// can not be read.
+func (m *chunkMap) load(cx, cy int) (*chunk, error) {
+	s := m.hdr.Size
+	c := &chunk{pos: [2]int{cx, cy}, tiles: make([]int, s*s)}
+	for i := range c.tiles {
+		c.tiles[i] = m.hdr.Fill
+	}
+
+	name := path.Join(m.dir, chunkFile(cx, cy))
+	data, err := fs.ReadFile(m.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return c, err
+	}
+
+	if len(data) != s*s*2 {
+		return c, fmt.Errorf("%s: has %d bytes, expected %d", name, len(data), s*s*2)
+	}
+	for i := range c.tiles {
+		c.tiles[i] = int(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+	return c, nil
+}
+
+// LoadTiles reads the chunks of a chunked level from the assets into Tiles, for tools
+// that need the whole map. Levels that are not chunked are left as they are.
+func (lvl *Level) LoadTiles() error {
+	if lvl.Chunks == nil || len(lvl.Tiles) > 0 {
+		return nil
+	}
+
+	m, err := newChunkMap(assets, *lvl.Chunks)
+	if err != nil {
+		return err
+	}
+
+	// Read chunk by chunk, so every file is read once and errors are not just logged.
+	tiles := make([][]int, m.hdr.Rows)
+	for x := range tiles {
+		tiles[x] = make([]int, m.hdr.Cols)
+	}
+	s := m.hdr.Size
+	for cx := 0; cx*s < m.hdr.Rows; cx++ {
+		for cy := 0; cy*s < m.hdr.Cols; cy++ {
+			c, err := m.load(cx, cy)
+			if err != nil {
+				return err
+			}
+			for x := cx * s; x < m.hdr.Rows && x < (cx+1)*s; x++ {
+				for y := cy * s; y < m.hdr.Cols && y < (cy+1)*s; y++ {
+					tiles[x][y] = c.tiles[x%s*s+y%s]
+				}
+			}
+		}
+	}
+
+	lvl.Tiles = tiles
+	return nil
+}
+
+// SaveChunkedLevel writes lvl to the maps directory in dir like SaveLevel, but with the
+// tiles in chunks of size by size in a directory next to it. The world streams the
+// chunks in as they are used, so the level does not have to fit in memory at once.
+// Chunks that only hold the most common tile are left out.
+func SaveChunkedLevel(dir, name string, lvl *Level, size int) error {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if len(lvl.Tiles) == 0 || len(lvl.Tiles[0]) == 0 {
+		return errors.New("level has no tiles")
+	}
+
+	hdr := Chunks{
+		Rows: len(lvl.Tiles),
+		Cols: len(lvl.Tiles[0]),
+		Size: size,
+	}
+
+	count := make(map[int]int)
+	for _, row := range lvl.Tiles {
+		if len(row) != hdr.Cols {
+			return errors.New("level rows differ in length")
+		}
+		for _, t := range row {
+			count[t]++
+		}
+	}
+	for t, n := range count {
+		if n > count[hdr.Fill] || (n == count[hdr.Fill] && t < hdr.Fill) {
+			hdr.Fill = t
+		}
+	}
+
+	tiles := make([]int, size*size)
+	return saveChunks(dir, name, lvl, hdr, func(cx, cy int) ([]int, error) {
+		for x := 0; x < size; x++ {
+			for y := 0; y < size; y++ {
+				t := hdr.Fill
+				if i, j := cx*size+x, cy*size+y; i < hdr.Rows && j < hdr.Cols {
+					t = lvl.Tiles[i][j]
+				}
+				tiles[x*size+y] = t
+			}
+		}
+		return tiles, nil
+	})
+}
+
+// SaveWorld writes the level of w to the maps directory in dir. The tiles of a chunked
+// world are written chunk by chunk, with the changed chunks from memory and the others
+// from the assets, so the map is never read into memory at once.
+func SaveWorld(dir, name string, w *World) error {
+	m, ok := w.tiles.(*chunkMap)
+	if !ok {
+		return SaveLevel(dir, name, w.Level())
+	}
+
+	return saveChunks(dir, name, w.Level(), m.hdr, func(cx, cy int) ([]int, error) {
+		if e, ok := m.chunks[[2]int{cx, cy}]; ok {
+			return e.Value.(*chunk).tiles, nil
+		}
+		c, err := m.load(cx, cy)
+		return c.tiles, err
+	})
+}
+
+// saveChunks writes the chunks that tiles returns to a directory next to the level and
+// then the level with hdr. The chunks are written to a new directory that replaces the
+// old one when it is done, since the old chunks may be the ones that are read.
+func saveChunks(dir, name string, lvl *Level, hdr Chunks, tiles func(cx, cy int) ([]int, error)) error {
+	hdr.Dir = name + ".chunks"
+	chunkDir := filepath.Join(dir, "maps", hdr.Dir)
+	tmpDir := chunkDir + ".new"
+
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return err
+	}
+
+	s := hdr.Size
+	data := make([]byte, s*s*2)
+	for cx := 0; cx*s < hdr.Rows; cx++ {
+		for cy := 0; cy*s < hdr.Cols; cy++ {
+			chunk, err := tiles(cx, cy)
+			if err != nil {
+				os.RemoveAll(tmpDir)
+				return err
+			}
+
+			empty := true
+			for i, t := range chunk {
+				if t < 0 || t > 0xffff {
+					os.RemoveAll(tmpDir)
+					return fmt.Errorf("tile %d can not be stored in a chunk", t)
+				}
+				empty = empty && t == hdr.Fill
+				binary.LittleEndian.PutUint16(data[i*2:], uint16(t))
+			}
+
+			if !empty {
+				if err := os.WriteFile(filepath.Join(tmpDir, chunkFile(cx, cy)), data, 0644); err != nil {
+					os.RemoveAll(tmpDir)
+					return err
+				}
+			}
+		}
+	}
+
+	if err := os.RemoveAll(chunkDir); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpDir, chunkDir); err != nil {
+		return err
+	}
+
+	c := *lvl
+	c.Tiles = nil
+	c.Chunks = &hdr
+	return SaveLevel(dir, name, &c)
+}
@@ -0,0 +1,253 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// chunkLevel is 5 by 7 tiles, which leaves partial chunks at the edges with a chunk
+// size of 2. The chunk at 0,2 only holds the fill tile and the one at 2,3 is partial.
+func chunkLevel() *Level {
+	return &Level{
+		Version: LevelVersion,
+		Name:    "chunks",
+		Tiles: [][]int{
+			{1, 1, 1, 1, 1, 1, 1},
+			{1, 0, 0, 1, 1, 1, 1},
+			{1, 0, 0, 9, 0, 0, 1},
+			{1, 0, 0, 1, 0, 0, 1},
+			{1, 1, 1, 1, 1, 1, 2},
+		},
+	}
+}
+
+// chunkAssets writes the tile tables to dir and makes it the assets.
+func chunkAssets(t *testing.T, dir string) func() {
+	if err := os.MkdirAll(filepath.Join(dir, "textures"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"textures/textures.json": "[]",
+		"textures/tiles.json":    `{"9": {"Triggers": ["door"]}}`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	old := assets
+	SetAssets(os.DirFS(dir))
+	return func() { SetAssets(old) }
+}
+
+func checkTiles(t *testing.T, name string, got, want [][]int) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d rows, want %d", name, len(got), len(want))
+	}
+	for x := range want {
+		for y := range want[x] {
+			if y >= len(got[x]) || got[x][y] != want[x][y] {
+				t.Fatalf("%s: got tiles %v, want %v", name, got, want)
+			}
+		}
+	}
+}
+
+func worldTiles(w *World) [][]int {
+	rows, cols := w.Size()
+	tiles := make([][]int, rows)
+	for x := range tiles {
+		tiles[x] = make([]int, cols)
+		for y := range tiles[x] {
+			tiles[x][y] = w.GetTile(x, y)
+		}
+	}
+	return tiles
+}
+
+func TestSaveChunkedLevel(t *testing.T) {
+	dir := t.TempDir()
+	defer chunkAssets(t, dir)()
+
+	lvl := chunkLevel()
+	if err := SaveChunkedLevel(dir, "big", lvl, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "maps", "big.chunks", chunkFile(0, 2))); !os.IsNotExist(err) {
+		t.Errorf("chunk of fill tiles was written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "maps", "big.chunks", chunkFile(2, 3))); err != nil {
+		t.Errorf("partial chunk was not written: %v", err)
+	}
+
+	loaded, err := LoadLevel("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Chunks == nil || len(loaded.Tiles) != 0 {
+		t.Fatal("level was not saved in chunks")
+	}
+	if c := *loaded.Chunks; c.Dir != "big.chunks" || c.Rows != 5 || c.Cols != 7 || c.Size != 2 || c.Fill != 1 {
+		t.Errorf("chunks are %+v", c)
+	}
+
+	if err := loaded.LoadTiles(); err != nil {
+		t.Fatal(err)
+	}
+	checkTiles(t, "LoadTiles", loaded.Tiles, lvl.Tiles)
+
+	loaded.Tiles = nil
+	w, err := NewWorldFromLevel(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTiles(t, "world", worldTiles(w), lvl.Tiles)
+	if n := w.NumZones(); n != 2 {
+		t.Errorf("chunked world has %d zones, want 2", n)
+	}
+}
+
+func TestChunkEviction(t *testing.T) {
+	defer func(n int) { ChunkCacheSize = n }(ChunkCacheSize)
+	ChunkCacheSize = 1
+
+	dir := t.TempDir()
+	defer chunkAssets(t, dir)()
+
+	want := chunkLevel().Tiles
+	if err := SaveChunkedLevel(dir, "big", chunkLevel(), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWorld("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := w.tiles.(*chunkMap)
+
+	checkTiles(t, "world", worldTiles(w), want)
+	if n := m.lru.Len(); n != 1 {
+		t.Errorf("%d chunks loaded, want 1", n)
+	}
+
+	// A changed chunk is kept when others are loaded, so the change is not lost.
+	w.SetTile(1, 1, 5)
+	want[1][1] = 5
+	checkTiles(t, "changed world", worldTiles(w), want)
+	if n := m.lru.Len(); n != 2 {
+		t.Errorf("%d chunks loaded, want the changed one and one more", n)
+	}
+
+	// Zones read the whole map through the cache.
+	if n := w.NumZones(); n != 2 {
+		t.Errorf("got %d zones, want 2", n)
+	}
+
+	// The level keeps its chunks and SaveWorld writes the changes.
+	if lvl := w.Level(); lvl.Chunks == nil || len(lvl.Tiles) != 0 {
+		t.Error("the level of a chunked world lost its chunks")
+	}
+
+	out := t.TempDir()
+	if err := SaveWorld(out, "copy", w); err != nil {
+		t.Fatal(err)
+	}
+	// Saving over the chunks the world reads from must not lose the unchanged ones.
+	if err := SaveWorld(dir, "big", w); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, saved := range []struct{ dir, name string }{{out, "copy"}, {dir, "big"}} {
+		data, err := os.ReadFile(filepath.Join(saved.dir, "maps", saved.name+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lvl, err := ParseLevel(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		SetAssets(os.DirFS(saved.dir))
+		if err := lvl.LoadTiles(); err != nil {
+			t.Fatal(err)
+		}
+		checkTiles(t, "saved "+saved.name, lvl.Tiles, want)
+	}
+}
+
+func TestTileMapBounds(t *testing.T) {
+	dir := t.TempDir()
+	defer chunkAssets(t, dir)()
+
+	if err := SaveChunkedLevel(dir, "big", chunkLevel(), 2); err != nil {
+		t.Fatal(err)
+	}
+	chunked, err := NewWorld("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grid, err := NewWorldFromLevel(chunkLevel())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 5,6 and 4,7 are inside the partial chunk at 2,3 but outside the map.
+	outside := [][2]int{{-1, 0}, {0, -1}, {5, 0}, {0, 7}, {5, 6}, {4, 7}, {-3, -3}, {100, 100}}
+
+	for _, w := range []*World{grid, chunked} {
+		name := fmt.Sprintf("%T", w.tiles)
+
+		changes := 0
+		unsubscribe := w.Subscribe(func(TileChange) { changes++ })
+
+		for _, p := range outside {
+			w.SetTile(p[0], p[1], 3)
+			w.tiles.setTile(p[0], p[1], 3)
+			if tile := w.GetTile(p[0], p[1]); tile != 0 {
+				t.Errorf("%s: tile %v outside the map reads %d", name, p, tile)
+			}
+			if tile := w.tiles.tile(p[0], p[1]); tile != 0 {
+				t.Errorf("%s: map tile %v outside the map reads %d", name, p, tile)
+			}
+			if !w.IsSolid(p[0], p[1]) {
+				t.Errorf("%s: tile %v outside the map is not solid", name, p)
+			}
+		}
+		unsubscribe()
+
+		if changes != 0 {
+			t.Errorf("%s: %d changes outside the map were reported", name, changes)
+		}
+		checkTiles(t, name, worldTiles(w), chunkLevel().Tiles)
+	}
+
+	// Short rows of a grid read as empty past their end.
+	ragged := gridMap{{1, 1, 1}, {1, 0}}
+	ragged.setTile(1, 2, 4)
+	if tile := ragged.tile(1, 2); tile != 0 {
+		t.Errorf("tile past the end of a short row reads %d", tile)
+	}
+}
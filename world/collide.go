@@ -0,0 +1,144 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+
+	"github.com/ungerik/go3d/float64/vec2"
+)
+
+// PlayerRadius is half the width of the box the player collides with.
+const PlayerRadius = 0.25
+
+// collisionGap keeps a mover just outside what it collides with, so it is not
+// touching the obstacle on the next move.
+const collisionGap = 1e-6
+
+// SpriteDef describes how a sprite behaves. Solid sprites block movement in a box
+// that reaches Radius from their center.
+type SpriteDef struct {
+	Solid  bool
+	Radius float64 `json:",omitempty"`
+}
+
+// SpriteDefs maps sprite names to their definitions. Sprites that are not in the
+// table do not block.
+type SpriteDefs map[string]SpriteDef
+
+// LoadSpriteDefs reads the sprite table from sprites.json in the assets. It is kept out
+// of the sprites directory, where old levels keep their sprites as sprites/<level>.json.
+func LoadSpriteDefs() (SpriteDefs, error) {
+	data, err := fs.ReadFile(assets, "sprites.json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return SpriteDefs{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var defs SpriteDefs
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return nil, fmt.Errorf("sprites.json: %v", err)
+	}
+	return defs, nil
+}
+
+// SpriteDefs returns the sprite table of the world.
+func (w *World) SpriteDefs() SpriteDefs {
+	return w.spriteDefs
+}
+
+// box is an axis aligned box from min to max.
+type box struct {
+	min, max vec2.T
+}
+
+func boxAround(p vec2.T, r float64) box {
+	return box{vec2.T{p[0] - r, p[1] - r}, vec2.T{p[0] + r, p[1] + r}}
+}
+
+func (b box) overlaps(o box) bool {
+	return b.min[0] < o.max[0] && b.max[0] > o.min[0] && b.min[1] < o.max[1] && b.max[1] > o.min[1]
+}
+
+// obstacles returns the solid tiles and sprites that a box of radius r at p can touch.
+func (w *World) obstacles(p vec2.T, r float64) []box {
+	var boxes []box
+
+	b := boxAround(p, r)
+	for x := int(math.Floor(b.min[0])); x <= int(math.Floor(b.max[0])); x++ {
+		for y := int(math.Floor(b.min[1])); y <= int(math.Floor(b.max[1])); y++ {
+			if w.IsSolid(x, y) {
+				boxes = append(boxes, box{vec2.T{float64(x), float64(y)}, vec2.T{float64(x + 1), float64(y + 1)}})
+			}
+		}
+	}
+
+	for _, e := range w.entities {
+		if def, ok := w.spriteDefs[e.Sprite]; ok && def.Solid {
+			boxes = append(boxes, boxAround(vec2.T{e.Pos[0], e.Pos[1]}, def.Radius))
+		}
+	}
+	return boxes
+}
+
+// Move returns where a box of radius r at pos ends up when moved by delta. It is stopped
+// by solid tiles, including everything outside the map, and by solid sprites. Each axis
+// is moved on its own, so a mover that hits a wall at an angle slides along it. Obstacles
+// the box already overlaps are ignored, so a mover that is stuck can get out.
+func (w *World) Move(pos, delta vec2.T, r float64) vec2.T {
+	// Steps no longer than r can not pass through anything thinner than the box.
+	steps := int(math.Ceil(math.Max(math.Abs(delta[0]), math.Abs(delta[1])) / r))
+	if steps < 1 {
+		steps = 1
+	}
+
+	for i := 0; i < steps; i++ {
+		for axis := 0; axis < 2; axis++ {
+			d := delta[axis] / float64(steps)
+			if d == 0 {
+				continue
+			}
+
+			reach := pos
+			reach[axis] += d
+			start := boxAround(pos, r)
+
+			next := pos[axis] + d
+			for _, o := range w.obstacles(reach, r+math.Abs(d)) {
+				if o.overlaps(start) || !o.overlaps(boxAround(reach, r)) {
+					continue
+				}
+
+				// Never back away from what is hit, the mover may already be closer than the gap.
+				if d > 0 {
+					next = math.Min(next, math.Max(pos[axis], o.min[axis]-r-collisionGap))
+				} else {
+					next = math.Max(next, math.Min(pos[axis], o.max[axis]+r+collisionGap))
+				}
+				reach[axis] = next
+			}
+			pos[axis] = next
+		}
+	}
+	return pos
+}
@@ -0,0 +1,140 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world_test
+
+import (
+	"math"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+	"github.com/ungerik/go3d/float64/vec2"
+)
+
+func collisionWorld(t *testing.T) *world.World {
+	old := world.Assets()
+	world.SetAssets(fstest.MapFS{
+		"textures/textures.json": {Data: []byte("[]")},
+		"textures/tiles.json":    {Data: []byte(`{"9": {"Triggers": ["door"]}}`)},
+		"sprites.json":           {Data: []byte(`{"barrel.png": {"Solid": true, "Radius": 0.3}}`)},
+	})
+	defer world.SetAssets(old)
+
+	// Open on the inside, with a door in the wall at 3,5 and an open edge at 6,3.
+	w, err := world.NewWorldFromLevel(&world.Level{
+		Tiles: [][]int{
+			{1, 1, 1, 1, 1, 1, 1},
+			{1, 0, 0, 0, 0, 1, 0},
+			{1, 0, 0, 0, 0, 1, 0},
+			{1, 0, 0, 0, 0, 9, 0},
+			{1, 0, 0, 0, 0, 1, 0},
+			{1, 1, 1, 1, 1, 1, 0},
+			{0, 0, 0, 0, 0, 0, 0},
+		},
+		Entities: []world.Entity{
+			{Pos: [2]float64{4.5, 1.5}, Sprite: "barrel.png"},
+			{Pos: [2]float64{4.5, 3.5}, Sprite: "light.png"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestMove(t *testing.T) {
+	const r = world.PlayerRadius
+
+	tests := []struct {
+		name       string
+		pos, delta vec2.T
+		want       vec2.T
+	}{
+		{"open floor", vec2.T{2.5, 2.5}, vec2.T{0.5, -0.25}, vec2.T{3, 2.25}},
+		{"stops at wall", vec2.T{2.5, 2.5}, vec2.T{-2, 0}, vec2.T{1 + r, 2.5}},
+		{"slides along wall", vec2.T{1.5, 2.5}, vec2.T{-1, 1}, vec2.T{1 + r, 3.5}},
+		{"stops in corner", vec2.T{2, 2}, vec2.T{-3, -3}, vec2.T{1 + r, 1 + r}},
+		{"no tunneling", vec2.T{2.5, 4.5}, vec2.T{0, 10}, vec2.T{2.5, 5 - r}},
+		{"closed door", vec2.T{3.5, 4.5}, vec2.T{0, 2}, vec2.T{3.5, 5 - r}},
+		{"blocking prop", vec2.T{2.5, 1.5}, vec2.T{3, 0}, vec2.T{4.2 - r, 1.5}},
+		{"slides along prop", vec2.T{3.5, 1.5}, vec2.T{1, 0.5}, vec2.T{4.2 - r, 2}},
+		{"walk through light", vec2.T{3.5, 3.5}, vec2.T{1, 0}, vec2.T{4.5, 3.5}},
+		{"stuck in wall", vec2.T{1.1, 2.5}, vec2.T{1, 0}, vec2.T{2.1, 2.5}},
+		{"map edge", vec2.T{6.5, 1.5}, vec2.T{0, -5}, vec2.T{6.5, r}},
+		{"zero move", vec2.T{2.5, 2.5}, vec2.T{}, vec2.T{2.5, 2.5}},
+	}
+
+	w := collisionWorld(t)
+	for _, tt := range tests {
+		got := w.Move(tt.pos, tt.delta, r)
+		if math.Abs(got[0]-tt.want[0]) > 1e-5 || math.Abs(got[1]-tt.want[1]) > 1e-5 {
+			t.Errorf("%s: moved from %v by %v to %v, want %v", tt.name, tt.pos, tt.delta, got, tt.want)
+		}
+	}
+}
+
+func TestMoveOpenDoor(t *testing.T) {
+	w := collisionWorld(t)
+	if !w.OpenDoor(3, 5) {
+		t.Fatal("no door at 3,5")
+	}
+
+	got := w.Move(vec2.T{3.5, 4.5}, vec2.T{0, 2}, world.PlayerRadius)
+	if want := (vec2.T{3.5, 6.5}); got != want {
+		t.Errorf("moved through open door to %v, want %v", got, want)
+	}
+
+	w.CloseDoor(3, 5)
+	got = w.Move(vec2.T{3.5, 4.5}, vec2.T{0, 2}, world.PlayerRadius)
+	if got[1] > 5-world.PlayerRadius {
+		t.Errorf("moved through closed door to %v", got)
+	}
+}
+
+func TestMoveSmallSteps(t *testing.T) {
+	// Many small moves into a corner, like a held key, end up where one large move does.
+	w := collisionWorld(t)
+
+	pos := vec2.T{2.5, 2.5}
+	for i := 0; i < 1000; i++ {
+		pos = w.Move(pos, vec2.T{-0.01, 0.004}, world.PlayerRadius)
+	}
+
+	want := w.Move(vec2.T{2.5, 2.5}, vec2.T{-10, 4}, world.PlayerRadius)
+	if math.Abs(pos[0]-want[0]) > 1e-5 || math.Abs(pos[1]-want[1]) > 1e-5 {
+		t.Errorf("ended at %v, want %v", pos, want)
+	}
+}
+
+func TestMoveLevel1(t *testing.T) {
+	old := world.Assets()
+	world.SetAssets(os.DirFS("../data"))
+	defer world.SetAssets(old)
+
+	w, err := world.NewWorld("level1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The corridor in front of the start is one tile wide and must not be blocked by props.
+	start, _ := w.Start()
+	if got := w.Move(start, vec2.T{-10, 0}, world.PlayerRadius); got[0] >= 18 {
+		t.Errorf("walking from the start stopped at %v", got)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Copy returns a deep copy of the level.
+func (lvl *Level) Copy() *Level {
+	c := *lvl
+	c.Tiles = copyLayer(lvl.Tiles)
+	c.Floor = copyLayer(lvl.Floor)
+	c.Ceiling = copyLayer(lvl.Ceiling)
+
+	if lvl.Entities != nil {
+		c.Entities = append([]Entity{}, lvl.Entities...)
+	}
+	if lvl.Exit != nil {
+		exit := *lvl.Exit
+		c.Exit = &exit
+	}
+	if lvl.Chunks != nil {
+		chunks := *lvl.Chunks
+		c.Chunks = &chunks
+	}
+	return &c
+}
+
+func copyLayer(layer [][]int) [][]int {
+	if layer == nil {
+		return nil
+	}
+
+	c := make([][]int, len(layer))
+	for i, row := range layer {
+		c[i] = append([]int{}, row...)
+	}
+	return c
+}
+
+// MarshalLevel encodes lvl in the layout of the maps in data/maps, with one tile row
+// and one entity per line, so saved levels give small diffs.
+func MarshalLevel(lvl *Level) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		err error
+	)
+
+	str := func(s string) string {
+		b, e := json.Marshal(s)
+		if e != nil && err == nil {
+			err = e
+		}
+		return string(b)
+	}
+	num := func(f float64) string {
+		if (math.IsNaN(f) || math.IsInf(f, 0)) && err == nil {
+			err = fmt.Errorf("can not encode %v in a level", f)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+
+	buf.WriteString("{\n")
+	fmt.Fprintf(&buf, "    \"Version\": %d,\n", lvl.Version)
+	fmt.Fprintf(&buf, "    \"Name\": %s,\n", str(lvl.Name))
+	fmt.Fprintf(&buf, "    \"Music\": %s,\n", str(lvl.Music))
+
+	c := lvl.Sky.Color
+	fmt.Fprintf(&buf, "    \"Sky\": {\"Color\": [%d, %d, %d], \"Texture\": %s},\n", c[0], c[1], c[2], str(lvl.Sky.Texture))
+
+	s := lvl.Start
+	fmt.Fprintf(&buf, "    \"Start\": {\"Pos\": [%s, %s], \"Angle\": %s},\n", num(s.Pos[0]), num(s.Pos[1]), num(s.Angle))
+
+	if e := lvl.Exit; e != nil {
+		fmt.Fprintf(&buf, "    \"Exit\": [%d, %d],\n", e[0], e[1])
+	}
+
+	if c := lvl.Chunks; c != nil {
+		fmt.Fprintf(&buf, "    \"Chunks\": {\"Dir\": %s, \"Rows\": %d, \"Cols\": %d, \"Size\": %d, \"Fill\": %d},\n", str(c.Dir), c.Rows, c.Cols, c.Size, c.Fill)
+	}
+
+	writeLayer := func(name string, layer [][]int) {
+		if len(layer) == 0 {
+			fmt.Fprintf(&buf, "    %q: [],\n", name)
+			return
+		}
+
+		fmt.Fprintf(&buf, "    %q: [\n", name)
+		for x, row := range layer {
+			cells := make([]string, len(row))
+			for y, t := range row {
+				cells[y] = strconv.Itoa(t)
+			}
+
+			sep := ","
+			if x == len(layer)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(&buf, "        [%s]%s\n", strings.Join(cells, ","), sep)
+		}
+		buf.WriteString("    ],\n")
+	}
+
+	writeLayer("Tiles", lvl.Tiles)
+	writeLayer("Floor", lvl.Floor)
+	writeLayer("Ceiling", lvl.Ceiling)
+
+	if len(lvl.Entities) == 0 {
+		buf.WriteString("    \"Entities\": []\n")
+	} else {
+		buf.WriteString("    \"Entities\": [\n")
+		for i, e := range lvl.Entities {
+			sep := ","
+			if i == len(lvl.Entities)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(&buf, "        {\"Pos\": [%s, %s], \"Sprite\": %s}%s\n", num(e.Pos[0]), num(e.Pos[1]), str(e.Sprite), sep)
+		}
+		buf.WriteString("    ]\n")
+	}
+
+	buf.WriteString("}\n")
+	return buf.Bytes(), err
+}
+
+// SaveLevel writes lvl to the maps directory in dir, where LoadLevel finds it
+// when dir is in the assets.
+func SaveLevel(dir, name string, lvl *Level) error {
+	data, err := MarshalLevel(lvl)
+	if err != nil {
+		return err
+	}
+
+	dir = filepath.Join(dir, "maps")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name+".json"), data, 0644)
+}
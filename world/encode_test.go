@@ -0,0 +1,154 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world_test
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+	"github.com/ungerik/go3d/float64/vec2"
+)
+
+func testLevel() *world.Level {
+	return &world.Level{
+		Version: world.LevelVersion,
+		Name:    "Test \"level\" å",
+		Music:   "music/theme.ogg",
+		Sky:     world.Sky{Color: [3]uint8{1, 2, 255}, Texture: "sky.png"},
+		Start:   world.Start{Pos: [2]float64{1.5, 2.25}, Angle: math.Pi / 3},
+		Exit:    &[2]int{2, 3},
+		Tiles: [][]int{
+			{1, 1, 1, 1, 1},
+			{1, 0, 0, 0, 1},
+			{1, 0, 0, 0, 1},
+			{1, 1, 1, 1, 1},
+		},
+		Floor:   [][]int{{0, 0, 0, 0, 0}, {0, 2, 2, 2, 0}, {0, 2, 3, 2, 0}, {0, 0, 0, 0, 0}},
+		Ceiling: [][]int{},
+		Entities: []world.Entity{
+			{Pos: [2]float64{1.5, 1.5}, Sprite: "barrel.png"},
+			{Pos: [2]float64{2.1, 3.0000001}, Sprite: "pillar.png"},
+		},
+	}
+}
+
+func TestMarshalLevelRoundTrip(t *testing.T) {
+	lvl := testLevel()
+
+	data, err := world.MarshalLevel(lvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := world.ParseLevel(data)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(got, lvl) {
+		t.Fatalf("level changed in round trip:\ngot  %+v\nwant %+v", got, lvl)
+	}
+
+	again, err := world.MarshalLevel(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Fatalf("encoding is not stable:\n%s\n%s", data, again)
+	}
+}
+
+func TestMarshalLevelMatchesData(t *testing.T) {
+	data, err := os.ReadFile("../data/maps/level1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lvl, err := world.ParseLevel(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := world.MarshalLevel(lvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("level1.json is not in the saved layout:\n%s", got)
+	}
+}
+
+func TestMarshalLevelInvalidFloat(t *testing.T) {
+	lvl := testLevel()
+	lvl.Start.Angle = math.NaN()
+
+	if _, err := world.MarshalLevel(lvl); err == nil {
+		t.Fatal("expected an error for NaN")
+	}
+}
+
+func TestWorldEditRoundTrip(t *testing.T) {
+	old := world.Assets()
+	world.SetAssets(fstest.MapFS{"textures/textures.json": {Data: []byte("[]")}})
+	defer world.SetAssets(old)
+
+	lvl := testLevel()
+	w, err := world.NewWorldFromLevel(lvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.SetTile(2, 2, 4)
+	w.SetStart(vec2.T{2.5, 1.5}, math.Pi)
+	w.AddSprite(world.Entity{Pos: [2]float64{1.5, 3.5}, Sprite: "greenlight.png"})
+	if i := w.SpriteAt(1, 1); i != 0 {
+		t.Fatalf("SpriteAt(1, 1) = %d, want 0", i)
+	}
+	if e := w.RemoveSprite(0); e.Sprite != "barrel.png" {
+		t.Fatalf("removed %s, want barrel.png", e.Sprite)
+	}
+	if i := w.SpriteAt(1, 1); i != -1 {
+		t.Fatalf("SpriteAt(1, 1) = %d after remove, want -1", i)
+	}
+
+	if !reflect.DeepEqual(lvl, testLevel()) {
+		t.Fatal("editing the world changed the level it was made from")
+	}
+
+	want := testLevel()
+	want.Tiles[2][2] = 4
+	want.Start = world.Start{Pos: [2]float64{2.5, 1.5}, Angle: math.Pi}
+	want.Entities = []world.Entity{want.Entities[1], {Pos: [2]float64{1.5, 3.5}, Sprite: "greenlight.png"}}
+
+	data, err := world.MarshalLevel(w.Level())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := world.ParseLevel(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("saved world does not load back:\ngot  %+v\nwant %+v", got, want)
+	}
+}
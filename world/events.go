@@ -0,0 +1,58 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+// TileChange is sent to observers when a tile changes. Old and New are the tile as
+// GetTile reports it, so a door that opens changes to zero.
+type TileChange struct {
+	X, Y     int
+	Old, New int
+}
+
+type tileObserver struct {
+	id int
+	fn func(TileChange)
+}
+
+// Subscribe calls fn for every tile that changes, until the returned function is called.
+// Observers are called in the order they subscribed.
+func (w *World) Subscribe(fn func(TileChange)) (unsubscribe func()) {
+	w.nextObserver++
+	id := w.nextObserver
+	w.observers = append(w.observers, tileObserver{id, fn})
+
+	return func() {
+		for i, o := range w.observers {
+			if o.id == id {
+				w.observers = append(w.observers[:i:i], w.observers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (w *World) notify(c TileChange) {
+	if c.Old == c.New {
+		return
+	}
+
+	// Observers may subscribe or unsubscribe while they are called.
+	for _, o := range w.observers {
+		o.fn(c)
+	}
+}
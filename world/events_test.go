@@ -0,0 +1,96 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world_test
+
+import (
+	"testing"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+)
+
+func TestSubscribeOrder(t *testing.T) {
+	w := zoneWorld(t)
+
+	var calls []int
+	for i := 0; i < 3; i++ {
+		i := i
+		w.Subscribe(func(world.TileChange) { calls = append(calls, i) })
+	}
+
+	w.SetTile(1, 1, 1)
+	if len(calls) != 3 || calls[0] != 0 || calls[1] != 1 || calls[2] != 2 {
+		t.Errorf("observers called in order %v", calls)
+	}
+}
+
+func TestUnsubscribeInCallback(t *testing.T) {
+	w := zoneWorld(t)
+
+	var first, second int
+	var unsubscribe func()
+	unsubscribe = w.Subscribe(func(world.TileChange) {
+		first++
+		unsubscribe()
+	})
+	w.Subscribe(func(world.TileChange) { second++ })
+
+	w.SetTile(1, 1, 1)
+	w.SetTile(1, 2, 1)
+	if first != 1 || second != 2 {
+		t.Errorf("observers called %d and %d times, want 1 and 2", first, second)
+	}
+
+	// Unsubscribing twice does nothing.
+	unsubscribe()
+	w.SetTile(1, 1, 0)
+	if second != 3 {
+		t.Errorf("remaining observer called %d times, want 3", second)
+	}
+}
+
+func TestNoEventWithoutChange(t *testing.T) {
+	w := zoneWorld(t)
+
+	var changes []world.TileChange
+	w.Subscribe(func(c world.TileChange) { changes = append(changes, c) })
+
+	w.SetTile(1, 1, 0)
+	w.SetTile(0, 0, 1)
+	w.CloseDoor(2, 3)
+	w.OpenDoor(2, 3)
+	w.OpenDoor(2, 3)
+
+	if len(changes) != 1 {
+		t.Errorf("got changes %v, want only the door opening", changes)
+	}
+}
+
+func TestDoorEvents(t *testing.T) {
+	w := zoneWorld(t)
+
+	var changes []world.TileChange
+	w.Subscribe(func(c world.TileChange) { changes = append(changes, c) })
+
+	w.OpenDoor(2, 3)
+	w.CloseDoor(2, 3)
+
+	want := []world.TileChange{{X: 2, Y: 3, Old: 9, New: 0}, {X: 2, Y: 3, Old: 0, New: 9}}
+	if len(changes) != len(want) || changes[0] != want[0] || changes[1] != want[1] {
+		t.Errorf("got changes %v, want %v", changes, want)
+	}
+}
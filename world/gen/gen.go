@@ -0,0 +1,236 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package gen builds random levels from a seed.
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/andreas-jonsson/go-wolf/engine"
+	"github.com/andreas-jonsson/go-wolf/world"
+)
+
+type Layout int
+
+const (
+	// LayoutBSP splits the map in two until the parts are room sized.
+	LayoutBSP Layout = iota
+	// LayoutRooms scatters rooms and joins them with corridors.
+	LayoutRooms
+)
+
+type Config func(*genConfig) error
+
+type genConfig struct {
+	width, height      int
+	minRoom, maxRoom   int
+	layout             Layout
+	themes             []int
+	doorTile, exitTile int
+	props              []string
+	propDensity        float64
+}
+
+func ConfigWithSize(width, height int) Config {
+	return func(cfg *genConfig) error {
+		if width < 16 || height < 16 {
+			return errors.New("level must be at least 16x16")
+		}
+		cfg.width, cfg.height = width, height
+		return nil
+	}
+}
+
+// ConfigWithRoomSize sets the smallest and largest room side, not counting walls.
+func ConfigWithRoomSize(min, max int) Config {
+	return func(cfg *genConfig) error {
+		if min < 3 || max < min {
+			return fmt.Errorf("invalid room size: %d-%d", min, max)
+		}
+		cfg.minRoom, cfg.maxRoom = min, max
+		return nil
+	}
+}
+
+func ConfigWithLayout(layout Layout) Config {
+	return func(cfg *genConfig) error {
+		cfg.layout = layout
+		return nil
+	}
+}
+
+// ConfigWithThemes sets the wall tiles rooms pick from.
+func ConfigWithThemes(tiles ...int) Config {
+	return func(cfg *genConfig) error {
+		if len(tiles) == 0 {
+			return errors.New("no theme tiles")
+		}
+		cfg.themes = tiles
+		return nil
+	}
+}
+
+// ConfigWithDoorTile sets the tile placed where corridors enter rooms. It has to be a
+// door in the tile table, or the rooms behind it can not be reached.
+func ConfigWithDoorTile(tile int) Config {
+	return func(cfg *genConfig) error {
+		cfg.doorTile = tile
+		return nil
+	}
+}
+
+// ConfigWithExitTile sets the wall tile of the room with the exit.
+func ConfigWithExitTile(tile int) Config {
+	return func(cfg *genConfig) error {
+		cfg.exitTile = tile
+		return nil
+	}
+}
+
+// ConfigWithProps sets the sprites scattered in rooms and how many there are per floor tile.
+func ConfigWithProps(density float64, sprites ...string) Config {
+	return func(cfg *genConfig) error {
+		if density < 0 || density > 1 {
+			return fmt.Errorf("invalid prop density: %v", density)
+		}
+		cfg.props, cfg.propDensity = sprites, density
+		return nil
+	}
+}
+
+func newGenConfig(configs []Config) (*genConfig, error) {
+	cfg := &genConfig{
+		width:       48,
+		height:      48,
+		minRoom:     4,
+		maxRoom:     9,
+		themes:      []int{2, 3, 4, 5, 6, 8},
+		doorTile:    9,
+		exitTile:    1,
+		props:       []string{"barrel.png", "pillar.png", "greenlight.png"},
+		propDensity: 0.03,
+	}
+
+	for _, c := range configs {
+		if err := c(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.maxRoom+2 > cfg.width/2 || cfg.maxRoom+2 > cfg.height/2 {
+		return nil, errors.New("rooms do not fit in the level")
+	}
+	return cfg, nil
+}
+
+// Generate builds a level from seed. The same seed and configuration always give the same level.
+func Generate(seed int64, configs ...Config) (*world.Level, error) {
+	cfg, err := newGenConfig(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{
+		cfg: cfg,
+		rnd: rand.New(rand.NewSource(seed)),
+	}
+
+	g.tiles = make([][]int, cfg.height)
+	for x := range g.tiles {
+		g.tiles[x] = make([]int, cfg.width)
+		for y := range g.tiles[x] {
+			g.tiles[x][y] = solid
+		}
+	}
+
+	switch cfg.layout {
+	case LayoutBSP:
+		g.splitBSP(rect{1, 1, cfg.height - 1, cfg.width - 1})
+	case LayoutRooms:
+		g.scatterRooms()
+	default:
+		return nil, fmt.Errorf("invalid layout: %d", cfg.layout)
+	}
+
+	if len(g.rooms) < 2 {
+		return nil, errors.New("could not fit two rooms in the level")
+	}
+
+	start := g.rooms[0].center()
+	exitRoom := g.farthest(start)
+	exit := g.rooms[exitRoom].center()
+
+	g.placeDoors()
+	g.paintWalls(exitRoom)
+
+	lvl := &world.Level{
+		Version: world.LevelVersion,
+		Name:    fmt.Sprintf("Generated %d", seed),
+		Sky:     world.Sky{Color: [3]uint8{75, 75, 75}},
+		Start: world.Start{
+			Pos:   [2]float64{float64(start.x) + 0.5, float64(start.y) + 0.5},
+			Angle: g.openAngle(start),
+		},
+		Exit:     &[2]int{exit.x, exit.y},
+		Tiles:    g.tiles,
+		Entities: g.scatterProps(start, exit),
+	}
+	return lvl, nil
+}
+
+// NewWorld generates a level and loads it like world.NewWorld and world.LoadSprites.
+func NewWorld(seed int64, configs ...Config) (*world.World, engine.SpriteInstances, error) {
+	lvl, err := Generate(seed, configs...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w, err := world.NewWorldFromLevel(lvl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, _ := newGenConfig(configs)
+	if w.TileDefs().Get(cfg.doorTile).Triggers&world.TriggerDoor == 0 {
+		return nil, nil, fmt.Errorf("tile %d is not a door", cfg.doorTile)
+	}
+
+	sprites, err := world.LoadLevelSprites(lvl)
+	if err != nil {
+		return nil, nil, err
+	}
+	return w, sprites, nil
+}
+
+// openAngle faces the start towards the longest free line of sight.
+func (g *generator) openAngle(p point) float64 {
+	best, angle := -1, 0.0
+	for i, d := range []point{{1, 0}, {0, 1}, {-1, 0}, {0, -1}} {
+		n := 0
+		for q := p.add(d); g.tiles[q.x][q.y] == empty; q = q.add(d) {
+			n++
+		}
+		if n > best {
+			best, angle = n, float64(i)*math.Pi/2
+		}
+	}
+	return angle
+}
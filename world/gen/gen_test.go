@@ -0,0 +1,83 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gen_test
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+	"github.com/andreas-jonsson/go-wolf/world/gen"
+)
+
+func TestGenerateValid(t *testing.T) {
+	old := world.Assets()
+	world.SetAssets(os.DirFS("../../data"))
+	defer world.SetAssets(old)
+
+	for _, layout := range []gen.Layout{gen.LayoutBSP, gen.LayoutRooms} {
+		for seed := int64(0); seed < 100; seed++ {
+			lvl, err := gen.Generate(seed, gen.ConfigWithLayout(layout))
+			if err != nil {
+				t.Errorf("layout %d, seed %d: %v", layout, seed, err)
+				continue
+			}
+
+			for _, p := range world.Validate(lvl) {
+				t.Errorf("layout %d, seed %d: %v", layout, seed, p)
+			}
+		}
+	}
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		a, err := gen.Generate(seed, gen.ConfigWithLayout(gen.LayoutRooms))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := gen.Generate(seed, gen.ConfigWithLayout(gen.LayoutRooms))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("seed %d gave two different levels", seed)
+		}
+	}
+
+	a, _ := gen.Generate(1)
+	b, _ := gen.Generate(2)
+	if reflect.DeepEqual(a.Tiles, b.Tiles) {
+		t.Error("seeds 1 and 2 gave the same level")
+	}
+}
+
+func TestNewWorldNeedsDoorTile(t *testing.T) {
+	old := world.Assets()
+	world.SetAssets(os.DirFS("../../data"))
+	defer world.SetAssets(old)
+
+	if _, _, err := gen.NewWorld(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := gen.NewWorld(1, gen.ConfigWithDoorTile(7)); err == nil {
+		t.Error("expected an error for a door tile that is a wall")
+	}
+}
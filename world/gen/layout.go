@@ -0,0 +1,319 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gen
+
+import (
+	"math/rand"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+)
+
+const (
+	empty = 0
+	// solid marks rock until paintWalls gives it a wall tile.
+	solid = -1
+)
+
+type point struct {
+	x, y int
+}
+
+func (p point) add(q point) point {
+	return point{p.x + q.x, p.y + q.y}
+}
+
+// rect covers rows x0 to x1 and columns y0 to y1, not including x1 and y1.
+type rect struct {
+	x0, y0, x1, y1 int
+}
+
+func (r rect) center() point {
+	return point{(r.x0 + r.x1) / 2, (r.y0 + r.y1) / 2}
+}
+
+func (r rect) grow(n int) rect {
+	return rect{r.x0 - n, r.y0 - n, r.x1 + n, r.y1 + n}
+}
+
+func (r rect) overlaps(o rect) bool {
+	return r.x0 < o.x1 && o.x0 < r.x1 && r.y0 < o.y1 && o.y0 < r.y1
+}
+
+func (r rect) contains(p point) bool {
+	return p.x >= r.x0 && p.x < r.x1 && p.y >= r.y0 && p.y < r.y1
+}
+
+type room struct {
+	rect
+	theme int
+}
+
+type generator struct {
+	cfg   *genConfig
+	rnd   *rand.Rand
+	tiles [][]int
+	rooms []room
+}
+
+func (g *generator) between(min, max int) int {
+	return min + g.rnd.Intn(max-min+1)
+}
+
+// addRoom carves a room inside r, keeping a wall on every side, and returns its index.
+func (g *generator) addRoom(r rect) int {
+	maxX, maxY := r.x1-r.x0-2, r.y1-r.y0-2
+	if maxX > g.cfg.maxRoom {
+		maxX = g.cfg.maxRoom
+	}
+	if maxY > g.cfg.maxRoom {
+		maxY = g.cfg.maxRoom
+	}
+
+	h, w := g.between(g.cfg.minRoom, maxX), g.between(g.cfg.minRoom, maxY)
+	x, y := g.between(r.x0+1, r.x1-1-h), g.between(r.y0+1, r.y1-1-w)
+
+	rm := room{rect{x, y, x + h, y + w}, g.cfg.themes[g.rnd.Intn(len(g.cfg.themes))]}
+	for i := rm.x0; i < rm.x1; i++ {
+		for j := rm.y0; j < rm.y1; j++ {
+			g.tiles[i][j] = empty
+		}
+	}
+
+	g.rooms = append(g.rooms, rm)
+	return len(g.rooms) - 1
+}
+
+// splitBSP splits r in two until the parts are room sized, and joins the halves with
+// corridors on the way back up. It returns a room in r.
+func (g *generator) splitBSP(r rect) int {
+	minPart := g.cfg.minRoom + 2
+	h, w := r.x1-r.x0, r.y1-r.y0
+	canX, canY := h >= 2*minPart, w >= 2*minPart
+
+	small := h <= g.cfg.maxRoom+2 && w <= g.cfg.maxRoom+2
+	if (!canX && !canY) || (small && g.rnd.Intn(3) == 0) {
+		return g.addRoom(r)
+	}
+
+	var a, b rect
+	if canX && (!canY || h > w || (h == w && g.rnd.Intn(2) == 0)) {
+		s := g.between(r.x0+minPart, r.x1-minPart)
+		a, b = rect{r.x0, r.y0, s, r.y1}, rect{s, r.y0, r.x1, r.y1}
+	} else {
+		s := g.between(r.y0+minPart, r.y1-minPart)
+		a, b = rect{r.x0, r.y0, r.x1, s}, rect{r.x0, s, r.x1, r.y1}
+	}
+
+	ra, rb := g.splitBSP(a), g.splitBSP(b)
+	g.connect(ra, rb)
+
+	if g.rnd.Intn(2) == 0 {
+		return ra
+	}
+	return rb
+}
+
+// scatterRooms places rooms at random and joins every room to the nearest one placed before it.
+func (g *generator) scatterRooms() {
+	cfg := g.cfg
+	for attempt := 0; attempt < 500; attempt++ {
+		h, w := g.between(cfg.minRoom, cfg.maxRoom), g.between(cfg.minRoom, cfg.maxRoom)
+		x, y := g.between(1, cfg.height-1-h), g.between(1, cfg.width-1-w)
+		r := rect{x, y, x + h, y + w}
+
+		fits := true
+		for _, rm := range g.rooms {
+			if r.grow(2).overlaps(rm.rect) {
+				fits = false
+				break
+			}
+		}
+
+		if fits {
+			n := g.addRoom(r.grow(1))
+			nearest, dist := -1, 0
+			for i, rm := range g.rooms[:n] {
+				a, b := rm.center(), g.rooms[n].center()
+				if d := abs(a.x-b.x) + abs(a.y-b.y); nearest < 0 || d < dist {
+					nearest, dist = i, d
+				}
+			}
+			if nearest >= 0 {
+				g.connect(nearest, n)
+			}
+		}
+	}
+}
+
+// connect carves an L-shaped corridor between the centers of two rooms.
+func (g *generator) connect(a, b int) {
+	p, q := g.rooms[a].center(), g.rooms[b].center()
+	if g.rnd.Intn(2) == 0 {
+		p, q = q, p
+	}
+
+	for x := p.x; x != q.x; x += sign(q.x - p.x) {
+		g.tiles[x][p.y] = empty
+	}
+	for y := p.y; y != q.y; y += sign(q.y - p.y) {
+		g.tiles[q.x][y] = empty
+	}
+	g.tiles[q.x][q.y] = empty
+}
+
+// placeDoors puts doors where corridors pass through the wall around a room.
+func (g *generator) placeDoors() {
+	isSolid := func(p point) bool { return g.tiles[p.x][p.y] == solid }
+	isEmpty := func(p point) bool { return g.tiles[p.x][p.y] == empty }
+
+	for _, rm := range g.rooms {
+		ring := rm.grow(1)
+		for x := ring.x0; x < ring.x1; x++ {
+			for y := ring.y0; y < ring.y1; y++ {
+				p := point{x, y}
+				onRows, onCols := x == ring.x0 || x == ring.x1-1, y == ring.y0 || y == ring.y1-1
+				if onRows == onCols || !isEmpty(p) {
+					continue
+				}
+
+				// The corridor must go straight through the wall.
+				across, along := point{1, 0}, point{0, 1}
+				if onCols {
+					across, along = along, across
+				}
+
+				sides := isSolid(p.add(along)) && isSolid(p.add(point{-along.x, -along.y}))
+				ends := isEmpty(p.add(across)) && isEmpty(p.add(point{-across.x, -across.y}))
+				if sides && ends {
+					g.tiles[x][y] = g.cfg.doorTile
+				}
+			}
+		}
+	}
+}
+
+// farthest returns the center of the room farthest from start, counting steps through
+// the open tiles. Doors are placed later, and since they open every room stays reachable.
+func (g *generator) farthest(start point) int {
+	dist := make([][]int, len(g.tiles))
+	for i := range dist {
+		dist[i] = make([]int, len(g.tiles[i]))
+		for j := range dist[i] {
+			dist[i][j] = -1
+		}
+	}
+
+	dist[start.x][start.y] = 0
+	queue := []point{start}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+
+		for _, d := range []point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			q := p.add(d)
+			if g.tiles[q.x][q.y] == empty && dist[q.x][q.y] < 0 {
+				dist[q.x][q.y] = dist[p.x][p.y] + 1
+				queue = append(queue, q)
+			}
+		}
+	}
+
+	best := 0
+	for i, rm := range g.rooms {
+		c, b := rm.center(), g.rooms[best].center()
+		if dist[c.x][c.y] > dist[b.x][b.y] {
+			best = i
+		}
+	}
+	return best
+}
+
+// paintWalls gives the rock around each room the room's theme, and the rest the
+// theme of the first room. The exit room is painted with the exit tile.
+func (g *generator) paintWalls(exit int) {
+	paint := func(r rect, tile int) {
+		for x := r.x0; x < r.x1; x++ {
+			for y := r.y0; y < r.y1; y++ {
+				if g.tiles[x][y] == solid {
+					g.tiles[x][y] = tile
+				}
+			}
+		}
+	}
+
+	paint(g.rooms[exit].grow(1), g.cfg.exitTile)
+	for _, rm := range g.rooms {
+		paint(rm.grow(1), rm.theme)
+	}
+	paint(rect{0, 0, len(g.tiles), len(g.tiles[0])}, g.rooms[0].theme)
+}
+
+// scatterProps places props in rooms. Props never touch each other, the room walls
+// or the start and exit, so they can not cut off a part of a room.
+func (g *generator) scatterProps(start, exit point) []world.Entity {
+	var entities []world.Entity
+	if len(g.cfg.props) == 0 {
+		return entities
+	}
+
+	taken := make(map[point]bool)
+	for _, rm := range g.rooms {
+		inner := rm.grow(-1)
+		for x := inner.x0; x < inner.x1; x++ {
+			for y := inner.y0; y < inner.y1; y++ {
+				p := point{x, y}
+				if g.rnd.Float64() >= g.cfg.propDensity {
+					continue
+				}
+
+				free := true
+				for i := -1; i <= 1; i++ {
+					for j := -1; j <= 1; j++ {
+						q := p.add(point{i, j})
+						if q == start || q == exit || taken[q] || g.tiles[q.x][q.y] != empty {
+							free = false
+						}
+					}
+				}
+
+				if free {
+					taken[p] = true
+					entities = append(entities, world.Entity{
+						Pos:    [2]float64{float64(x) + 0.5, float64(y) + 0.5},
+						Sprite: g.cfg.props[g.rnd.Intn(len(g.cfg.props))],
+					})
+				}
+			}
+		}
+	}
+	return entities
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+func sign(a int) int {
+	if a < 0 {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,435 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"testing/fstest"
+)
+
+func le(values ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	return buf.Bytes()
+}
+
+// bmpFile builds a bitmap from a DIB header, the palette or masks that follow it and the pixel data.
+func bmpFile(info, extra, pixels []byte) []byte {
+	offset := 14 + len(info) + len(extra)
+	file := le([2]byte{'B', 'M'}, uint32(offset+len(pixels)), uint32(0), uint32(offset))
+	return append(append(append(file, info...), extra...), pixels...)
+}
+
+func bmpInfo(size uint32, w, h int32, bpp uint16, compression, colors uint32) []byte {
+	info := le(size, w, h, uint16(1), bpp, compression, uint32(0), int32(0), int32(0), colors, uint32(0))
+	return append(info, make([]byte, int(size)-40)...)
+}
+
+func bmpInfoMasks(w, h int32, bpp uint16, masks [4]uint32) []byte {
+	return append(le(uint32(56), w, h, uint16(1), bpp, uint32(bmpBitFields), uint32(0), int32(0), int32(0), uint32(0), uint32(0)), le(masks)...)
+}
+
+// bgr0 is a BMP palette entry.
+func bgr0(r, g, b byte) []byte {
+	return []byte{b, g, r, 0}
+}
+
+func cat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+type imageTest struct {
+	name string
+	data []byte
+	// want holds the rows of the image, top to bottom.
+	want [][]color.RGBA
+}
+
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	green = color.RGBA{0, 255, 0, 255}
+	blue  = color.RGBA{0, 0, 255, 255}
+	white = color.RGBA{255, 255, 255, 255}
+	black = color.RGBA{0, 0, 0, 255}
+)
+
+var bmpTests = []imageTest{
+	{
+		"24 bit bottom up",
+		bmpFile(bmpInfo(40, 2, 2, 24, bmpRGB, 0), nil, cat(
+			[]byte{0, 0, 255, 0, 255, 0, 0, 0},
+			[]byte{255, 0, 0, 255, 255, 255, 0, 0},
+		)),
+		[][]color.RGBA{{blue, white}, {red, green}},
+	},
+	{
+		"24 bit top down",
+		bmpFile(bmpInfo(40, 1, -2, 24, bmpRGB, 0), nil, cat(
+			[]byte{0, 0, 255, 0},
+			[]byte{255, 0, 0, 0},
+		)),
+		[][]color.RGBA{{red}, {blue}},
+	},
+	{
+		"8 bit with short palette",
+		bmpFile(bmpInfo(40, 3, 2, 8, bmpRGB, 2), cat(bgr0(255, 0, 0), bgr0(0, 0, 255)), cat(
+			[]byte{1, 1, 0, 0},
+			[]byte{0, 1, 5, 0},
+		)),
+		[][]color.RGBA{{red, blue, red}, {blue, blue, red}},
+	},
+	{
+		"4 bit",
+		bmpFile(bmpInfo(40, 3, 1, 4, bmpRGB, 3), cat(bgr0(255, 0, 0), bgr0(0, 255, 0), bgr0(0, 0, 255)), []byte{0x21, 0x00, 0, 0}),
+		[][]color.RGBA{{blue, green, red}},
+	},
+	{
+		"1 bit across bytes",
+		bmpFile(bmpInfo(40, 9, 1, 1, bmpRGB, 0), cat(bgr0(0, 0, 0), bgr0(255, 255, 255)), []byte{0xa0, 0x80, 0, 0}),
+		[][]color.RGBA{{white, black, white, black, black, black, black, black, white}},
+	},
+	{
+		"RLE8 runs, absolute mode, delta and end of line",
+		bmpFile(bmpInfo(40, 4, 3, 8, bmpRLE8, 3), cat(bgr0(255, 0, 0), bgr0(0, 255, 0), bgr0(0, 0, 255)), []byte{
+			4, 1, 0, 0, // bottom row green, end of line
+			0, 3, 2, 0, 2, 0, // absolute blue red blue and padding
+			0, 2, 0, 1, // move to the next row, keep x at 3
+			1, 2, // blue at 3
+			0, 1, // end of bitmap
+		}),
+		[][]color.RGBA{{red, red, red, blue}, {blue, red, blue, red}, {green, green, green, green}},
+	},
+	{
+		"RLE4 runs and absolute mode",
+		bmpFile(bmpInfo(40, 5, 2, 4, bmpRLE4, 3), cat(bgr0(255, 0, 0), bgr0(0, 255, 0), bgr0(0, 0, 255)), []byte{
+			5, 0x12, 0, 0, // green blue green blue green
+			0, 3, 0x21, 0x00, // absolute blue green red, padded to a word
+			0, 1,
+		}),
+		[][]color.RGBA{{blue, green, red, red, red}, {green, blue, green, blue, green}},
+	},
+	{
+		"16 bit 555",
+		bmpFile(bmpInfo(40, 2, 1, 16, bmpRGB, 0), nil, le(uint16(0x7c00), uint16(0x001f))),
+		[][]color.RGBA{{red, blue}},
+	},
+	{
+		"16 bit 565 bit fields",
+		bmpFile(bmpInfo(40, 2, 1, 16, bmpBitFields, 0), le([3]uint32{0xf800, 0x07e0, 0x001f}), le(uint16(0x07e0), uint16(0xffff))),
+		[][]color.RGBA{{green, white}},
+	},
+	{
+		"32 bit",
+		bmpFile(bmpInfo(40, 1, 1, 32, bmpRGB, 0), nil, []byte{0, 255, 0, 0}),
+		[][]color.RGBA{{green}},
+	},
+	{
+		"32 bit fields with full alpha mask in a v3 header",
+		bmpFile(bmpInfoMasks(2, 1, 32, [4]uint32{0xff, 0xff00, 0xff0000, 0}), nil, le(uint32(0xff), uint32(0xffffff))),
+		[][]color.RGBA{{red, white}},
+	},
+	{
+		"32 bit field of 32 bits",
+		bmpFile(bmpInfoMasks(1, 1, 32, [4]uint32{0xffffffff, 0, 0, 0}), nil, le(uint32(0xffffffff))),
+		[][]color.RGBA{{color.RGBA{255, 0, 0, 255}}},
+	},
+	{
+		"v5 header",
+		bmpFile(bmpInfo(124, 1, 1, 24, bmpRGB, 0), nil, []byte{255, 0, 0, 0}),
+		[][]color.RGBA{{blue}},
+	},
+	{
+		"OS/2 header",
+		bmpFile(le(uint32(12), uint16(2), uint16(1), uint16(1), uint16(1)), []byte{0, 0, 255, 0, 255, 0}, []byte{0x40, 0, 0, 0}),
+		[][]color.RGBA{{red, green}},
+	},
+}
+
+func checkImage(t *testing.T, name string, img image.Image, want [][]color.RGBA) {
+	t.Helper()
+
+	if b := img.Bounds(); b != image.Rect(0, 0, len(want[0]), len(want)) {
+		t.Errorf("%s: bounds %v", name, b)
+		return
+	}
+	for y, row := range want {
+		for x, c := range row {
+			if got := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA); got != c {
+				t.Errorf("%s: pixel %d,%d is %v, want %v", name, x, y, got, c)
+			}
+		}
+	}
+}
+
+// checkTruncated decodes every prefix of data, which must fail without panicking.
+func checkTruncated(t *testing.T, name string, data []byte) {
+	t.Helper()
+
+	for n := 0; n < len(data); n++ {
+		if _, _, err := image.Decode(bytes.NewReader(data[:n])); err == nil {
+			t.Errorf("%s: no error when truncated to %d bytes", name, n)
+		}
+	}
+}
+
+func TestDecodeBMP(t *testing.T) {
+	for _, tt := range bmpTests {
+		img, format, err := image.Decode(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if format != "bmp" {
+			t.Errorf("%s: detected as %s", tt.name, format)
+		}
+		checkImage(t, tt.name, img, tt.want)
+		checkTruncated(t, tt.name, tt.data)
+
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(tt.data))
+		if err != nil || cfg.Width != len(tt.want[0]) || cfg.Height != len(tt.want) {
+			t.Errorf("%s: config %+v, %v", tt.name, cfg, err)
+		}
+	}
+}
+
+func TestDecodeBMPErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"signature", append([]byte("BX"), bmpFile(bmpInfo(40, 1, 1, 24, bmpRGB, 0), nil, make([]byte, 4))[2:]...)},
+		{"header size", bmpFile(le(uint32(20), make([]byte, 16)), nil, make([]byte, 4))},
+		{"zero width", bmpFile(bmpInfo(40, 0, 1, 24, bmpRGB, 0), nil, nil)},
+		{"too large", bmpFile(bmpInfo(40, 1<<20, 1<<20, 24, bmpRGB, 0), nil, nil)},
+		{"bit depth", bmpFile(bmpInfo(40, 1, 1, 2, bmpRGB, 0), make([]byte, 16), make([]byte, 4))},
+		{"RLE8 at 24 bits", bmpFile(bmpInfo(40, 1, 1, 24, bmpRLE8, 0), nil, []byte{0, 1})},
+		{"RLE4 at 8 bits", bmpFile(bmpInfo(40, 1, 1, 8, bmpRLE4, 1), make([]byte, 4), []byte{0, 1})},
+		{"unknown compression", bmpFile(bmpInfo(40, 1, 1, 24, 9, 0), nil, make([]byte, 4))},
+	}
+
+	for _, tt := range tests {
+		if _, err := decodeBMP(bytes.NewReader(tt.data)); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func pcxFile(bpp, planes byte, xmin, ymin, xmax, ymax, bytesPerLine uint16, pal16 []byte, data []byte) []byte {
+	var hdr pcxHeader
+	hdr.Manufacturer, hdr.Version, hdr.Encoding, hdr.BitsPerPixel = 0x0a, 5, 1, bpp
+	hdr.XMin, hdr.YMin, hdr.XMax, hdr.YMax = xmin, ymin, xmax, ymax
+	hdr.Planes, hdr.BytesPerLine = planes, bytesPerLine
+	copy(hdr.Palette[:], pal16)
+	return append(le(hdr), data...)
+}
+
+func pcxPalette(colors ...color.RGBA) []byte {
+	pal := make([]byte, 769)
+	pal[0] = pcxPaletteMarker
+	for i, c := range colors {
+		pal[1+i*3], pal[2+i*3], pal[3+i*3] = c.R, c.G, c.B
+	}
+	return pal
+}
+
+var pcxTests = []imageTest{
+	{
+		"8 bit with a run across lines",
+		pcxFile(8, 1, 0, 0, 2, 1, 4, nil, cat([]byte{1, 0xc5, 2, 3, 0}, pcxPalette(red, green, blue))),
+		[][]color.RGBA{{green, blue, blue}, {blue, blue, color.RGBA{0, 0, 0, 255}}},
+	},
+	{
+		"8 bit with an offset origin and padding before the palette",
+		pcxFile(8, 1, 5, 7, 5, 7, 2, nil, cat([]byte{0xc2, 1, 0, 0}, pcxPalette(red, green))),
+		[][]color.RGBA{{green}},
+	},
+	{
+		"24 bit in three planes",
+		pcxFile(8, 3, 0, 0, 1, 0, 2, nil, []byte{0xc1, 255, 0, 0, 0xc1, 255, 0, 0xc1, 255}),
+		[][]color.RGBA{{red, color.RGBA{0, 255, 255, 255}}},
+	},
+	{
+		"1 bit",
+		pcxFile(1, 1, 0, 0, 9, 0, 2, nil, []byte{0xc1, 0xa0, 0xc1, 0xc0}),
+		[][]color.RGBA{{white, black, white, black, black, black, black, black, white, white}},
+	},
+	{
+		"1 bit in four planes",
+		pcxFile(1, 4, 0, 0, 1, 0, 1, cat([]byte{0, 0, 0}, []byte{255, 0, 0}, []byte{0, 255, 0}, []byte{0, 0, 255}), []byte{0x80, 0x40, 0, 0}),
+		[][]color.RGBA{{red, green}},
+	},
+}
+
+func TestDecodePCX(t *testing.T) {
+	for _, tt := range pcxTests {
+		img, format, err := image.Decode(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if format != "pcx" {
+			t.Errorf("%s: detected as %s", tt.name, format)
+		}
+		checkImage(t, tt.name, img, tt.want)
+		checkTruncated(t, tt.name, tt.data)
+	}
+}
+
+func TestDecodePCXErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"encoding", append(pcxFile(8, 3, 0, 0, 0, 0, 1, nil, []byte{1, 2, 3})[:2], append([]byte{0}, pcxFile(8, 3, 0, 0, 0, 0, 1, nil, []byte{1, 2, 3})[3:]...)...)},
+		{"size", pcxFile(8, 3, 2, 0, 1, 0, 2, nil, nil)},
+		{"too large", pcxFile(8, 3, 0, 0, 65535, 65535, 65535, nil, nil)},
+		{"format", pcxFile(4, 1, 0, 0, 0, 0, 1, nil, []byte{0})},
+		{"line size", pcxFile(8, 1, 0, 0, 3, 0, 2, nil, []byte{0, 0})},
+		{"huge line size", pcxFile(8, 1, 0, 0, 0, 0, 60000, nil, []byte{0})},
+		{"palette marker", pcxFile(8, 1, 0, 0, 0, 0, 1, nil, cat([]byte{0}, make([]byte, 769)))},
+	}
+
+	for _, tt := range tests {
+		if _, err := decodePCX(bytes.NewReader(tt.data)); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSpriteSheet(t *testing.T) {
+	// Two by two frames in two columns and rows, with a margin of one and a spacing of one.
+	colors := []color.RGBA{red, green, blue, white}
+	sheet := image.NewRGBA(image.Rect(0, 0, 7, 7))
+	for i, c := range colors {
+		x, y := 1+i%2*3, 1+i/2*3
+		for j := 0; j < 4; j++ {
+			sheet.Set(x+j%2, y+j/2, c)
+		}
+	}
+
+	fsys := fstest.MapFS{
+		"sprites/guard/sheet.png":  {Data: encodePNG(t, sheet)},
+		"sprites/guard.sheet.json": {Data: []byte(`{"Image": "guard/sheet.png", "FrameWidth": 2, "FrameHeight": 2, "Margin": 1, "Spacing": 1, "Frames": ["stand", "walk"]}`)},
+		"sprites/bad.sheet.json":   {Data: []byte(`{"Image": "guard/sheet.png", "FrameWidth": 0, "FrameHeight": 2}`)},
+		"sprites/barrel.png":       {Data: encodePNG(t, image.NewRGBA(image.Rect(0, 0, 1, 1)))},
+		"sprites/notes.txt":        {Data: []byte("not a sprite")},
+	}
+
+	old := assets
+	SetAssets(fsys)
+	defer SetAssets(old)
+
+	for _, tt := range []struct {
+		frame string
+		want  color.RGBA
+	}{{"stand", red}, {"walk", green}, {"0", red}, {"2", blue}, {"3", white}} {
+		img, err := loadImage("sprites/guard.sheet.json#" + tt.frame)
+		if err != nil {
+			t.Errorf("frame %s: %v", tt.frame, err)
+			continue
+		}
+		checkImage(t, "frame "+tt.frame, img, [][]color.RGBA{{tt.want, tt.want}, {tt.want, tt.want}})
+	}
+
+	for _, frame := range []string{"4", "-1", "run"} {
+		if _, err := loadImage("sprites/guard.sheet.json#" + frame); err == nil {
+			t.Errorf("frame %s: expected an error", frame)
+		}
+	}
+	if _, err := loadImage("sprites/bad.sheet.json#0"); err == nil {
+		t.Error("expected an error for a sheet without a frame width")
+	}
+
+	// The bad sheet makes listing fail, so leave it out.
+	delete(fsys, "sprites/bad.sheet.json")
+	names, err := SpriteNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"barrel.png", "guard.sheet.json#2", "guard.sheet.json#3", "guard.sheet.json#stand", "guard.sheet.json#walk"}
+	if len(names) != len(want) {
+		t.Fatalf("got sprites %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("got sprites %v, want %v", names, want)
+			break
+		}
+	}
+}
+
+func TestForgetAssets(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	fsys := fstest.MapFS{
+		"sprites/a.png":        {Data: encodePNG(t, img)},
+		"sprites/b.png":        {Data: encodePNG(t, img)},
+		"sprites/a.sheet.json": {Data: []byte(`{"Image": "a.png", "FrameWidth": 1, "FrameHeight": 1}`)},
+	}
+
+	old := assets
+	SetAssets(fsys)
+	defer SetAssets(old)
+
+	for _, name := range []string{"sprites/a.sheet.json#0", "sprites/a.sheet.json#1", "sprites/b.png"} {
+		if _, err := loadImage(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cached := func() map[string]bool {
+		cache.Lock()
+		defer cache.Unlock()
+
+		m := make(map[string]bool)
+		for name := range cache.images {
+			m[name] = true
+		}
+		return m
+	}
+
+	if c := cached(); len(c) != 4 || !c["sprites/a.png"] {
+		t.Fatalf("cached %v", c)
+	}
+
+	// Changing the sheet image drops it and its frames, but not other images.
+	forgetAssets([]string{"sprites/a.png"})
+	if c := cached(); len(c) != 1 || !c["sprites/b.png"] {
+		t.Errorf("cached %v after the sheet image changed", c)
+	}
+
+	loadImage("sprites/a.sheet.json#0")
+	forgetAssets([]string{"sprites/a.sheet.json"})
+	if c := cached(); len(c) != 2 || !c["sprites/a.png"] || !c["sprites/b.png"] {
+		t.Errorf("cached %v after the sheet changed", c)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LevelVersion is the newest level file version this package can read.
+const LevelVersion = 1
+
+type (
+	// Level is the on-disk description of a level in data/maps. Exit is the
+	// tile that ends the level, if it has one. Large levels keep their tiles in
+	// Chunks instead of Tiles.
+	Level struct {
+		Version  int
+		Name     string
+		Music    string
+		Sky      Sky
+		Start    Start
+		Exit     *[2]int `json:",omitempty"`
+		Chunks   *Chunks `json:",omitempty"`
+		Tiles    [][]int
+		Floor    [][]int
+		Ceiling  [][]int
+		Entities []Entity
+	}
+
+	// Chunks describes a tile layer of Rows by Cols tiles, stored in chunks of Size
+	// by Size tiles in the directory Dir next to the level.
+	Chunks struct {
+		Dir        string
+		Rows, Cols int
+		Size       int
+		Fill       int
+	}
+
+	Sky struct {
+		Color   [3]uint8
+		Texture string
+	}
+
+	// Start is the player start. Angle is the view direction in radians,
+	// where zero looks along the positive X axis.
+	Start struct {
+		Pos   [2]float64
+		Angle float64
+	}
+
+	Entity struct {
+		Pos    [2]float64
+		Sprite string
+	}
+)
+
+var legacySky = Sky{Color: [3]uint8{75, 75, 75}}
+
+// LoadLevel reads a level from the maps directory of the assets. Besides the level
+// format it accepts the old bare tile arrays and maps made with the Tiled editor.
+func LoadLevel(name string) (*Level, error) {
+	file := path.Join("maps", name+".tmx")
+	if _, err := fs.Stat(assets, file); errors.Is(err, fs.ErrNotExist) {
+		file = path.Join("maps", name+".json")
+	}
+
+	data, err := fs.ReadFile(assets, file)
+	if err != nil {
+		return nil, err
+	}
+	return parseLevelFile(file, data)
+}
+
+// LoadLevelFile reads a level from a .json or .tmx file outside the assets.
+func LoadLevelFile(file string) (*Level, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseLevelFile(file, data)
+}
+
+func parseLevelFile(file string, data []byte) (*Level, error) {
+	if strings.EqualFold(filepath.Ext(file), ".tmx") {
+		lvl, err := readTMX(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		return lvl, nil
+	}
+
+	lvl, err := ParseLevel(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	if isLegacyLevel(data) {
+		lvl.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if err := loadLegacySprites(lvl.Name, lvl); err != nil {
+			return nil, err
+		}
+	}
+	return lvl, nil
+}
+
+// ParseLevel decodes a level in any of the JSON formats LoadLevel accepts. The sprites
+// of old bare tile arrays are not included, since they live in a separate file.
+func ParseLevel(data []byte) (*Level, error) {
+	data = bytes.TrimSpace(data)
+	switch {
+	case isLegacyLevel(data):
+		return migrateLegacyLevel(data)
+	case isTiledJSON(data):
+		return readTiledJSON(data)
+	}
+
+	lvl := new(Level)
+	if err := json.Unmarshal(data, lvl); err != nil {
+		return nil, err
+	}
+
+	if lvl.Version < 1 || lvl.Version > LevelVersion {
+		return nil, fmt.Errorf("unsupported level version: %d", lvl.Version)
+	}
+	return lvl, nil
+}
+
+func isLegacyLevel(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '['
+}
+
+// migrateLegacyLevel converts the old bare tile array format.
+func migrateLegacyLevel(data []byte) (*Level, error) {
+	lvl := &Level{
+		Version: LevelVersion,
+		Sky:     legacySky,
+	}
+
+	if err := json.Unmarshal(data, &lvl.Tiles); err != nil {
+		return nil, err
+	}
+
+	// Old maps have no start position, so use the first free tile.
+	for x, row := range lvl.Tiles {
+		for y, t := range row {
+			if t == 0 {
+				lvl.Start = Start{Pos: [2]float64{float64(x) + 0.5, float64(y) + 0.5}, Angle: math.Pi}
+				return lvl, nil
+			}
+		}
+	}
+	return lvl, nil
+}
+
+// loadLegacySprites reads the sprites of an old level from the sprites directory.
+func loadLegacySprites(name string, lvl *Level) error {
+	fp, err := assets.Open(path.Join("sprites", name+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	dec := json.NewDecoder(fp)
+	return dec.Decode(&lvl.Entities)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+)
+
+func TestMigrateLegacyLevel(t *testing.T) {
+	old := world.Assets()
+	world.SetAssets(fstest.MapFS{
+		"maps/old.json":        {Data: []byte("\n  [[1, 1, 1, 1],\n   [1, 1, 0, 1],\n   [1, 0, 0, 1],\n   [1, 1, 1, 1]]\n")},
+		"sprites/old.json":     {Data: []byte(`[{"Pos": [2.5, 1.5], "Sprite": "barrel.png"}]`)},
+		"maps/bare.json":       {Data: []byte(`[[0, 1], [1, 1]]`)},
+		"maps/walls.json":      {Data: []byte(`[[1, 1], [1, 1]]`)},
+		"maps/broken.json":     {Data: []byte(`[[1, "a"]]`)},
+		"maps/sprites.json":    {Data: []byte(`[[1, 0]]`)},
+		"sprites/sprites.json": {Data: []byte(`[{"Pos": [0.5, 1.5], "Sprite": "pillar.png"}]`)},
+		"sprites.json":         {Data: []byte(`{"pillar.png": {"Solid": true, "Radius": 0.3}}`)},
+	})
+	defer world.SetAssets(old)
+
+	lvl, err := world.LoadLevel("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &world.Level{
+		Version: world.LevelVersion,
+		Name:    "old",
+		Sky:     world.Sky{Color: [3]uint8{75, 75, 75}},
+		// The first free tile, looking the way the old maps did.
+		Start:    world.Start{Pos: [2]float64{1.5, 2.5}, Angle: math.Pi},
+		Tiles:    [][]int{{1, 1, 1, 1}, {1, 1, 0, 1}, {1, 0, 0, 1}, {1, 1, 1, 1}},
+		Entities: []world.Entity{{Pos: [2]float64{2.5, 1.5}, Sprite: "barrel.png"}},
+	}
+	if !reflect.DeepEqual(lvl, want) {
+		t.Errorf("got %+v, want %+v", lvl, want)
+	}
+
+	// Without a sprite file the level has no entities.
+	if lvl, err := world.LoadLevel("bare"); err != nil || len(lvl.Entities) != 0 || lvl.Start.Pos != [2]float64{0.5, 0.5} {
+		t.Errorf("bare level: %+v, %v", lvl, err)
+	}
+	if lvl, err := world.LoadLevel("walls"); err != nil || lvl.Start != (world.Start{}) {
+		t.Errorf("level without free tiles: %+v, %v", lvl, err)
+	}
+	if _, err := world.LoadLevel("broken"); err == nil {
+		t.Error("expected an error for a bad tile array")
+	}
+
+	// The sprite table does not get in the way of a legacy level named sprites.
+	lvl, err = world.LoadLevel("sprites")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lvl.Entities) != 1 || lvl.Entities[0].Sprite != "pillar.png" {
+		t.Errorf("entities are %v", lvl.Entities)
+	}
+	defs, err := world.LoadSpriteDefs()
+	if err != nil || !defs["pillar.png"].Solid {
+		t.Errorf("sprite table is %v, %v", defs, err)
+	}
+}
+
+func TestParseLegacyLevel(t *testing.T) {
+	// ParseLevel has no name to look up sprites by.
+	lvl, err := world.ParseLevel([]byte(`[[1, 0]]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lvl.Name != "" || lvl.Entities != nil || lvl.Version != world.LevelVersion {
+		t.Errorf("got %+v", lvl)
+	}
+}
+
+func TestParseLevelVersion(t *testing.T) {
+	for _, v := range []string{"0", "2"} {
+		if _, err := world.ParseLevel([]byte(`{"Version": ` + v + `, "Tiles": [[1]]}`)); err == nil {
+			t.Errorf("version %s: expected an error", v)
+		}
+	}
+}
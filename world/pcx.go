@@ -0,0 +1,205 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// ZSoft PCX images with 1 bit in 1 or 4 planes, or 8 bits in 1 or 3 planes. 8-bit
+// images with one plane have their 256 color palette at the end of the file.
+
+type pcxHeader struct {
+	Manufacturer, Version, Encoding, BitsPerPixel byte
+	XMin, YMin, XMax, YMax                        uint16
+	HDPI, VDPI                                    uint16
+	Palette                                       [48]byte
+	Reserved, Planes                              byte
+	BytesPerLine, PaletteInfo                     uint16
+	HScreen, VScreen                              uint16
+	Filler                                        [54]byte
+}
+
+const pcxPaletteMarker = 0x0c
+
+func init() {
+	// The version byte is 0, 2, 3, 4 or 5 and the encoding is always 1.
+	for _, version := range "\x00\x02\x03\x04\x05" {
+		image.RegisterFormat("pcx", "\x0a"+string(version)+"\x01", decodePCX, decodePCXConfig)
+	}
+}
+
+func readPCXHeader(r io.Reader) (*pcxHeader, error) {
+	h := new(pcxHeader)
+	if err := binary.Read(r, binary.LittleEndian, h); err != nil {
+		return nil, err
+	}
+
+	if h.Manufacturer != 0x0a || h.Encoding != 1 {
+		return nil, errors.New("pcx: invalid header")
+	}
+	if h.XMax < h.XMin || h.YMax < h.YMin {
+		return nil, errors.New("pcx: invalid size")
+	}
+	if h.width()*h.height() > maxImagePixels {
+		return nil, errors.New("pcx: image is too large")
+	}
+
+	switch {
+	case h.BitsPerPixel == 1 && (h.Planes == 1 || h.Planes == 4):
+	case h.BitsPerPixel == 8 && (h.Planes == 1 || h.Planes == 3):
+	default:
+		return nil, fmt.Errorf("pcx: unsupported format, %d bits in %d planes", h.BitsPerPixel, h.Planes)
+	}
+
+	if int(h.BytesPerLine)*8 < h.width()*int(h.BitsPerPixel) || int(h.BytesPerLine) > h.width()+8 {
+		return nil, errors.New("pcx: invalid line size")
+	}
+	return h, nil
+}
+
+func (h *pcxHeader) width() int {
+	return int(h.XMax) - int(h.XMin) + 1
+}
+
+func (h *pcxHeader) height() int {
+	return int(h.YMax) - int(h.YMin) + 1
+}
+
+// headerPalette returns the 16 color palette in the header.
+func (h *pcxHeader) headerPalette(n int) color.Palette {
+	pal := make(color.Palette, n)
+	for i := range pal {
+		pal[i] = color.RGBA{h.Palette[i*3], h.Palette[i*3+1], h.Palette[i*3+2], 255}
+	}
+	return pal
+}
+
+func decodePCXConfig(r io.Reader) (image.Config, error) {
+	h, err := readPCXHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	cfg := image.Config{Width: h.width(), Height: h.height(), ColorModel: color.RGBAModel}
+	if h.Planes == 1 || h.BitsPerPixel == 1 {
+		cfg.ColorModel = color.Palette{}
+	}
+	return cfg, nil
+}
+
+func decodePCX(r io.Reader) (image.Image, error) {
+	h, err := readPCXHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(r)
+	w, ht := h.width(), h.height()
+	lineSize := int(h.BytesPerLine) * int(h.Planes)
+
+	// Runs may cross line boundaries, so the data is decoded as one stream.
+	data := make([]byte, lineSize*ht)
+	for i := 0; i < len(data); {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		count := 1
+		if b&0xc0 == 0xc0 {
+			count = int(b & 0x3f)
+			if b, err = br.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+		for ; count > 0 && i < len(data); count-- {
+			data[i] = b
+			i++
+		}
+	}
+
+	bounds := image.Rect(0, 0, w, ht)
+	switch {
+	case h.BitsPerPixel == 8 && h.Planes == 3:
+		img := image.NewRGBA(bounds)
+		for y := 0; y < ht; y++ {
+			line := data[y*lineSize:]
+			for x := 0; x < w; x++ {
+				p := img.Pix[y*img.Stride+x*4:]
+				p[0], p[1], p[2], p[3] = line[x], line[int(h.BytesPerLine)+x], line[2*int(h.BytesPerLine)+x], 255
+			}
+		}
+		return img, nil
+	case h.BitsPerPixel == 8:
+		pal, err := readPCXPalette(br)
+		if err != nil {
+			return nil, err
+		}
+
+		img := image.NewPaletted(bounds, pal)
+		for y := 0; y < ht; y++ {
+			copy(img.Pix[y*img.Stride:y*img.Stride+w], data[y*lineSize:])
+		}
+		return img, nil
+	default:
+		// One bit per plane, where the planes make up the bits of the color index.
+		pal := color.Palette{color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}}
+		if h.Planes == 4 {
+			pal = h.headerPalette(16)
+		}
+
+		img := image.NewPaletted(bounds, pal)
+		for y := 0; y < ht; y++ {
+			line := data[y*lineSize:]
+			for x := 0; x < w; x++ {
+				var c byte
+				for p := 0; p < int(h.Planes); p++ {
+					bit := line[p*int(h.BytesPerLine)+x/8] >> uint(7-x%8) & 1
+					c |= bit << uint(p)
+				}
+				img.Pix[y*img.Stride+x] = c
+			}
+		}
+		return img, nil
+	}
+}
+
+func readPCXPalette(r io.Reader) (color.Palette, error) {
+	// The palette is the last 769 bytes, but there may be padding after the image data.
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < 769 || rest[len(rest)-769] != pcxPaletteMarker {
+		return nil, errors.New("pcx: missing palette")
+	}
+
+	raw := rest[len(rest)-768:]
+	pal := make(color.Palette, 256)
+	for i := range pal {
+		pal[i] = color.RGBA{raw[i*3], raw[i*3+1], raw[i*3+2], 255}
+	}
+	return pal, nil
+}
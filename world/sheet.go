@@ -0,0 +1,149 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SheetExt is the extension of sprite sheet descriptions.
+const SheetExt = ".sheet.json"
+
+// SpriteSheet cuts an image into frames of the same size, numbered left to right and
+// top to bottom. A frame is used as "name.sheet.json#3", or by name if Frames names it.
+//
+//	{"Image": "guard.pcx", "FrameWidth": 64, "FrameHeight": 64, "Frames": ["stand", "walk1"]}
+type SpriteSheet struct {
+	Image                   string
+	FrameWidth, FrameHeight int
+	// Margin is the space around the grid, Spacing the space between frames.
+	Margin, Spacing int
+	Frames          []string `json:",omitempty"`
+}
+
+func loadSpriteSheet(file string) (*SpriteSheet, error) {
+	data, err := fs.ReadFile(assets, file)
+	if err != nil {
+		return nil, err
+	}
+
+	sheet := new(SpriteSheet)
+	if err := json.Unmarshal(data, sheet); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if sheet.FrameWidth <= 0 || sheet.FrameHeight <= 0 {
+		return nil, fmt.Errorf("%s: invalid frame size", file)
+	}
+	return sheet, nil
+}
+
+// grid returns the number of frame columns and rows in an image of the given size.
+func (s *SpriteSheet) grid(size image.Point) (cols, rows int) {
+	cols = (size.X - 2*s.Margin + s.Spacing) / (s.FrameWidth + s.Spacing)
+	rows = (size.Y - 2*s.Margin + s.Spacing) / (s.FrameHeight + s.Spacing)
+	return
+}
+
+// frameIndex looks up a frame by name or number.
+func (s *SpriteSheet) frameIndex(frame string) (int, bool) {
+	for i, name := range s.Frames {
+		if name == frame {
+			return i, true
+		}
+	}
+	i, err := strconv.Atoi(frame)
+	return i, err == nil
+}
+
+func loadFrame(file, frame string) (*image.RGBA, []string, error) {
+	sheet, err := loadSpriteSheet(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	imageFile := path.Join(path.Dir(file), sheet.Image)
+	img, err := loadImage(imageFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cols, rows := sheet.grid(img.Bounds().Size())
+	i, ok := sheet.frameIndex(frame)
+	if !ok || i < 0 || i >= cols*rows {
+		return nil, nil, fmt.Errorf("%s: no frame %s", file, frame)
+	}
+
+	min := image.Pt(
+		sheet.Margin+i%cols*(sheet.FrameWidth+sheet.Spacing),
+		sheet.Margin+i/cols*(sheet.FrameHeight+sheet.Spacing),
+	)
+
+	// Frames get their own image, since the engine expects textures to start at the origin.
+	dst := image.NewRGBA(image.Rect(0, 0, sheet.FrameWidth, sheet.FrameHeight))
+	draw.Draw(dst, dst.Bounds(), img, min, draw.Src)
+	return dst, []string{file, imageFile}, nil
+}
+
+var imageExts = map[string]bool{".png": true, ".gif": true, ".bmp": true, ".pcx": true}
+
+// SpriteNames lists the sprites in the assets, with every frame of the sprite sheets.
+func SpriteNames() ([]string, error) {
+	entries, err := fs.ReadDir(assets, "sprites")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		switch {
+		case strings.HasSuffix(name, SheetExt):
+			sheet, err := loadSpriteSheet(path.Join("sprites", name))
+			if err != nil {
+				return nil, err
+			}
+
+			img, err := loadImage(path.Join("sprites", sheet.Image))
+			if err != nil {
+				return nil, err
+			}
+
+			cols, rows := sheet.grid(img.Bounds().Size())
+			for i := 0; i < cols*rows; i++ {
+				frame := strconv.Itoa(i)
+				if i < len(sheet.Frames) {
+					frame = sheet.Frames[i]
+				}
+				names = append(names, name+"#"+frame)
+			}
+		case imageExts[strings.ToLower(path.Ext(name))]:
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,455 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Tiled maps are read from data/maps as name.tmx or as a Tiled JSON export in name.json.
+//
+// Tile layers named "floor" and "ceiling" become the floor and ceiling layers, the first other
+// tile layer holds the walls. Tile N of a tileset becomes texture N, and empty cells are zero.
+//
+// In object layers, an object named or typed "player" is the player start. Its "angle" property
+// is the view direction in degrees, where 0 looks right and 90 looks down in the editor.
+// Every other object is a sprite, read from the "sprite" property or else the object name.
+//
+// The map properties "name", "music" and "skycolor" fill in the level metadata.
+//
+// Reference: https://doc.mapeditor.org/en/stable/reference/tmx-map-format/
+
+const (
+	tiledFlipFlags = 0xe0000000
+	tiledPlayer    = "player"
+	tiledExit      = "exit"
+)
+
+type (
+	tiledProperty struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	}
+
+	tiledProperties []tiledProperty
+
+	tiledTileset struct {
+		FirstGID int `xml:"firstgid,attr" json:"firstgid"`
+	}
+
+	tiledData struct {
+		Encoding    string `xml:"encoding,attr"`
+		Compression string `xml:"compression,attr"`
+		Text        string `xml:",chardata"`
+		Tiles       []struct {
+			GID uint32 `xml:"gid,attr"`
+		} `xml:"tile"`
+	}
+
+	tiledObject struct {
+		Name       string          `xml:"name,attr" json:"name"`
+		Type       string          `xml:"type,attr" json:"type"`
+		Class      string          `xml:"class,attr" json:"class"`
+		GID        uint32          `xml:"gid,attr" json:"gid"`
+		X          float64         `xml:"x,attr" json:"x"`
+		Y          float64         `xml:"y,attr" json:"y"`
+		Width      float64         `xml:"width,attr" json:"width"`
+		Height     float64         `xml:"height,attr" json:"height"`
+		Properties tiledProperties `xml:"properties>property" json:"properties"`
+	}
+
+	tiledLayer struct {
+		Type        string          `json:"type"`
+		Name        string          `xml:"name,attr" json:"name"`
+		Width       int             `xml:"width,attr" json:"width"`
+		Height      int             `xml:"height,attr" json:"height"`
+		Data        json.RawMessage `json:"data"`
+		Encoding    string          `json:"encoding"`
+		Compression string          `json:"compression"`
+		Objects     []tiledObject   `json:"objects"`
+		Layers      []tiledLayer    `json:"layers"`
+	}
+
+	tiledMap struct {
+		Infinite   bool            `xml:"infinite,attr" json:"infinite"`
+		Width      int             `xml:"width,attr" json:"width"`
+		Height     int             `xml:"height,attr" json:"height"`
+		TileWidth  float64         `xml:"tilewidth,attr" json:"tilewidth"`
+		TileHeight float64         `xml:"tileheight,attr" json:"tileheight"`
+		Properties tiledProperties `xml:"properties>property" json:"properties"`
+		Tilesets   []tiledTileset  `xml:"tileset" json:"tilesets"`
+		Layers     []tiledLayer    `xml:"-" json:"layers"`
+	}
+
+	// The XML format keeps tile and object layers in separate elements.
+	tmxLayer struct {
+		Name   string    `xml:"name,attr"`
+		Width  int       `xml:"width,attr"`
+		Height int       `xml:"height,attr"`
+		Data   tiledData `xml:"data"`
+	}
+
+	tmxGroup struct {
+		Layers       []tmxLayer       `xml:"layer"`
+		ObjectGroups []tmxObjectGroup `xml:"objectgroup"`
+		Groups       []tmxGroup       `xml:"group"`
+	}
+
+	tmxObjectGroup struct {
+		Objects []tiledObject `xml:"object"`
+	}
+
+	tmxMap struct {
+		tiledMap
+		tmxGroup
+	}
+)
+
+func (p tiledProperties) get(name string) (string, bool) {
+	for _, prop := range p {
+		if strings.EqualFold(prop.Name, name) {
+			return prop.Value, true
+		}
+	}
+	return "", false
+}
+
+func (p *tiledProperty) UnmarshalJSON(data []byte) error {
+	var prop struct {
+		Name  string      `json:"name"`
+		Value interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(data, &prop); err != nil {
+		return err
+	}
+
+	p.Name, p.Value = prop.Name, fmt.Sprint(prop.Value)
+	return nil
+}
+
+// UnmarshalJSON accepts both the current property list and the object used by old Tiled versions.
+func (p *tiledProperties) UnmarshalJSON(data []byte) error {
+	var list []tiledProperty
+	if err := json.Unmarshal(data, &list); err == nil {
+		*p = list
+		return nil
+	}
+
+	var props map[string]interface{}
+	if err := json.Unmarshal(data, &props); err != nil {
+		return err
+	}
+	for k, v := range props {
+		*p = append(*p, tiledProperty{Name: k, Value: fmt.Sprint(v)})
+	}
+	return nil
+}
+
+// isTiledJSON checks for a Tiled JSON export without decoding the layers.
+func isTiledJSON(data []byte) bool {
+	var header struct {
+		Type         string `json:"type"`
+		TiledVersion string `json:"tiledversion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return false
+	}
+	return header.Type == "map" || header.TiledVersion != ""
+}
+
+func readTiledJSON(data []byte) (*Level, error) {
+	var m tiledMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	b := newTiledBuilder(&m)
+	var walk func(layers []tiledLayer) error
+	walk = func(layers []tiledLayer) error {
+		for _, l := range layers {
+			switch l.Type {
+			case "tilelayer":
+				gids, err := decodeTiledJSONData(&l)
+				if err != nil {
+					return fmt.Errorf("layer %s: %v", l.Name, err)
+				}
+				if err := b.addTileLayer(l.Name, l.Width, l.Height, gids); err != nil {
+					return err
+				}
+			case "objectgroup":
+				b.addObjects(l.Objects)
+			case "group":
+				if err := walk(l.Layers); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(m.Layers); err != nil {
+		return nil, err
+	}
+	return b.level()
+}
+
+func decodeTiledJSONData(l *tiledLayer) ([]uint32, error) {
+	if l.Encoding != "base64" {
+		var gids []uint32
+		err := json.Unmarshal(l.Data, &gids)
+		return gids, err
+	}
+
+	var text string
+	if err := json.Unmarshal(l.Data, &text); err != nil {
+		return nil, err
+	}
+	return decodeTiledBase64(text, l.Compression, l.Width*l.Height)
+}
+
+func readTMX(data []byte) (*Level, error) {
+	var m tmxMap
+	if err := xml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	b := newTiledBuilder(&m.tiledMap)
+	var walk func(g *tmxGroup) error
+	walk = func(g *tmxGroup) error {
+		for _, l := range g.Layers {
+			gids, err := decodeTMXData(&l.Data, l.Width*l.Height)
+			if err != nil {
+				return fmt.Errorf("layer %s: %v", l.Name, err)
+			}
+			if err := b.addTileLayer(l.Name, l.Width, l.Height, gids); err != nil {
+				return err
+			}
+		}
+		for _, og := range g.ObjectGroups {
+			b.addObjects(og.Objects)
+		}
+		for i := range g.Groups {
+			if err := walk(&g.Groups[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(&m.tmxGroup); err != nil {
+		return nil, err
+	}
+	return b.level()
+}
+
+func decodeTMXData(d *tiledData, size int) ([]uint32, error) {
+	switch d.Encoding {
+	case "":
+		gids := make([]uint32, len(d.Tiles))
+		for i, t := range d.Tiles {
+			gids[i] = t.GID
+		}
+		return gids, nil
+	case "csv":
+		var gids []uint32
+		for _, f := range strings.Split(d.Text, ",") {
+			if f = strings.TrimSpace(f); f == "" {
+				continue
+			}
+			v, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, err
+			}
+			gids = append(gids, uint32(v))
+		}
+		return gids, nil
+	case "base64":
+		return decodeTiledBase64(d.Text, d.Compression, size)
+	}
+	return nil, fmt.Errorf("unsupported encoding: %s", d.Encoding)
+}
+
+func decodeTiledBase64(text, compression string, size int) ([]uint32, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, err
+	}
+
+	var rd io.Reader = bytes.NewReader(raw)
+	switch compression {
+	case "":
+	case "zlib":
+		if rd, err = zlib.NewReader(rd); err != nil {
+			return nil, err
+		}
+	case "gzip":
+		if rd, err = gzip.NewReader(rd); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", compression)
+	}
+
+	gids := make([]uint32, size)
+	if err := binary.Read(rd, binary.LittleEndian, gids); err != nil {
+		return nil, err
+	}
+	return gids, nil
+}
+
+type tiledBuilder struct {
+	m   *tiledMap
+	lvl Level
+
+	hasWalls, hasStart bool
+}
+
+func newTiledBuilder(m *tiledMap) *tiledBuilder {
+	b := &tiledBuilder{m: m}
+	b.lvl.Version = LevelVersion
+	b.lvl.Sky = legacySky
+
+	if v, ok := m.Properties.get("name"); ok {
+		b.lvl.Name = v
+	}
+	if v, ok := m.Properties.get("music"); ok {
+		b.lvl.Music = v
+	}
+	if v, ok := m.Properties.get("skycolor"); ok {
+		if c, err := parseTiledColor(v); err == nil {
+			b.lvl.Sky.Color = c
+		}
+	}
+	return b
+}
+
+// parseTiledColor reads the #rrggbb and #aarrggbb colors Tiled writes.
+func parseTiledColor(s string) ([3]uint8, error) {
+	var c [3]uint8
+	s = strings.TrimPrefix(s, "#")
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || (len(s) != 6 && len(s) != 8) {
+		return c, fmt.Errorf("invalid color: %s", s)
+	}
+	return [3]uint8{uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+// tile converts a global tile id to a texture index, counting from one.
+func (b *tiledBuilder) tile(gid uint32) int {
+	gid &^= tiledFlipFlags
+	if gid == 0 {
+		return 0
+	}
+
+	firstGID := 1
+	for _, ts := range b.m.Tilesets {
+		if ts.FirstGID <= int(gid) && ts.FirstGID > firstGID {
+			firstGID = ts.FirstGID
+		}
+	}
+	return int(gid) - firstGID + 1
+}
+
+func (b *tiledBuilder) addTileLayer(name string, width, height int, gids []uint32) error {
+	if b.m.Infinite {
+		return fmt.Errorf("layer %s: infinite maps are not supported", name)
+	}
+	if len(gids) != width*height {
+		return fmt.Errorf("layer %s: expected %d tiles, got %d", name, width*height, len(gids))
+	}
+
+	layer := make([][]int, height)
+	for y := range layer {
+		layer[y] = make([]int, width)
+		for x := range layer[y] {
+			layer[y][x] = b.tile(gids[y*width+x])
+		}
+	}
+
+	switch strings.ToLower(name) {
+	case "floor":
+		b.lvl.Floor = layer
+	case "ceiling":
+		b.lvl.Ceiling = layer
+	default:
+		if !b.hasWalls {
+			b.lvl.Tiles = layer
+			b.hasWalls = true
+		}
+	}
+	return nil
+}
+
+func (o *tiledObject) is(kind string) bool {
+	return strings.EqualFold(o.Name, kind) || strings.EqualFold(o.Type, kind) || strings.EqualFold(o.Class, kind)
+}
+
+func (b *tiledBuilder) addObjects(objects []tiledObject) {
+	for _, o := range objects {
+		// Tile objects are anchored at the bottom, other objects at the top left corner.
+		x, y := o.X+o.Width/2, o.Y+o.Height/2
+		if o.GID != 0 {
+			y = o.Y - o.Height/2
+		}
+
+		// Map X is the row and map Y the column.
+		pos := [2]float64{y / b.m.TileHeight, x / b.m.TileWidth}
+
+		if o.is(tiledExit) {
+			b.lvl.Exit = &[2]int{int(pos[0]), int(pos[1])}
+			continue
+		}
+
+		if o.is(tiledPlayer) {
+			var degrees float64
+			if v, ok := o.Properties.get("angle"); ok {
+				degrees, _ = strconv.ParseFloat(v, 64)
+			}
+			b.lvl.Start = Start{Pos: pos, Angle: math.Pi/2 - degrees*math.Pi/180}
+			b.hasStart = true
+			continue
+		}
+
+		sprite, ok := o.Properties.get("sprite")
+		if !ok {
+			sprite = o.Name
+		}
+		b.lvl.Entities = append(b.lvl.Entities, Entity{Pos: pos, Sprite: sprite})
+	}
+}
+
+func (b *tiledBuilder) level() (*Level, error) {
+	if !b.hasWalls {
+		return nil, fmt.Errorf("no tile layer with walls")
+	}
+	if !b.hasStart {
+		return nil, fmt.Errorf("no %s object", tiledPlayer)
+	}
+	return &b.lvl, nil
+}
@@ -0,0 +1,228 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tiledGIDs is a 3 by 2 wall layer. Gid 3 has the horizontal flip flag, gid 12 the vertical
+// one and comes from the second tileset, which starts at 10.
+var tiledGIDs = []uint32{1, 2, 3 | 0x80000000, 0, 12 | 0x40000000, 1}
+
+var tiledWalls = [][]int{{1, 2, 3}, {0, 3, 1}}
+
+func tiledBase64(t *testing.T, compression string) string {
+	raw := new(bytes.Buffer)
+	binary.Write(raw, binary.LittleEndian, tiledGIDs)
+
+	var buf bytes.Buffer
+	switch compression {
+	case "":
+		buf.Write(raw.Bytes())
+	case "zlib":
+		w := zlib.NewWriter(&buf)
+		w.Write(raw.Bytes())
+		w.Close()
+	case "gzip":
+		w := gzip.NewWriter(&buf)
+		w.Write(raw.Bytes())
+		w.Close()
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func tiledCSV() string {
+	var fields []string
+	for _, gid := range tiledGIDs {
+		fields = append(fields, fmt.Sprint(gid))
+	}
+	return strings.Join(fields, ",\n")
+}
+
+// tmx wraps layers in a 3 by 2 map of 64 pixel tiles.
+func tmx(attrs, body string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" width="3" height="2" tilewidth="64" tileheight="64" ` + attrs + `>
+ <properties>
+  <property name="name" value="Test"/>
+  <property name="skycolor" value="#ff102030"/>
+ </properties>
+ <tileset firstgid="1" source="walls.tsx"/>
+ <tileset firstgid="10" source="more.tsx"/>
+` + body + `
+</map>`
+}
+
+func tmxLayerXML(name, data string) string {
+	return `<layer id="1" name="` + name + `" width="3" height="2">` + data + `</layer>`
+}
+
+const tmxPlayer = `<objectgroup id="2" name="objects">
+  <object id="1" name="player" x="96" y="32"><properties><property name="angle" value="90"/></properties></object>
+ </objectgroup>`
+
+func tiledJSON(layers string) string {
+	return `{"type": "map", "tiledversion": "1.10.2", "width": 3, "height": 2, "tilewidth": 64, "tileheight": 64,
+		"properties": [{"name": "name", "type": "string", "value": "Test"}, {"name": "skycolor", "type": "color", "value": "#ff102030"}],
+		"tilesets": [{"firstgid": 1, "source": "walls.tsx"}, {"firstgid": 10, "source": "more.tsx"}],
+		"layers": [` + layers + `]}`
+}
+
+const tiledJSONPlayer = `{"type": "objectgroup", "name": "objects", "objects": [
+	{"name": "player", "x": 96, "y": 32, "properties": [{"name": "angle", "type": "float", "value": 90}]}]}`
+
+func tiledLevel(edit func(lvl *Level)) *Level {
+	lvl := &Level{
+		Version: LevelVersion,
+		Name:    "Test",
+		Sky:     Sky{Color: [3]uint8{0x10, 0x20, 0x30}},
+		Start:   Start{Pos: [2]float64{0.5, 1.5}, Angle: 0},
+		Tiles:   tiledWalls,
+	}
+	if edit != nil {
+		edit(lvl)
+	}
+	return lvl
+}
+
+func TestReadTiled(t *testing.T) {
+	gidTiles := ""
+	for _, gid := range tiledGIDs {
+		gidTiles += fmt.Sprintf(`<tile gid="%d"/>`, gid)
+	}
+	floor := [][]int{{4, 4, 4}, {4, 4, 4}}
+
+	tests := []struct {
+		name string
+		tmx  bool
+		data string
+		want *Level
+	}{
+		{"tmx xml tiles", true, tmx("", tmxLayerXML("walls", "<data>"+gidTiles+"</data>")+tmxPlayer), tiledLevel(nil)},
+		{"tmx csv", true, tmx("", tmxLayerXML("walls", `<data encoding="csv">`+tiledCSV()+"</data>")+tmxPlayer), tiledLevel(nil)},
+		{"tmx base64", true, tmx("", tmxLayerXML("walls", `<data encoding="base64">`+tiledBase64(t, "")+"</data>")+tmxPlayer), tiledLevel(nil)},
+		{"tmx zlib", true, tmx("", tmxLayerXML("walls", `<data encoding="base64" compression="zlib">`+tiledBase64(t, "zlib")+"</data>")+tmxPlayer), tiledLevel(nil)},
+		{"tmx gzip", true, tmx("", tmxLayerXML("walls", `<data encoding="base64" compression="gzip">`+tiledBase64(t, "gzip")+"</data>")+tmxPlayer), tiledLevel(nil)},
+		{
+			"tmx floor and ceiling before the walls", true,
+			tmx("", tmxLayerXML("Floor", `<data encoding="csv">4,4,4,4,4,4</data>`)+
+				tmxLayerXML("ceiling", `<data encoding="csv">4,4,4,4,4,4</data>`)+
+				tmxLayerXML("walls", `<data encoding="csv">`+tiledCSV()+"</data>")+
+				tmxLayerXML("decals", `<data encoding="csv">1,1,1,1,1,1</data>`)+tmxPlayer),
+			tiledLevel(func(lvl *Level) { lvl.Floor, lvl.Ceiling = floor, floor }),
+		},
+		{
+			"tmx nested groups", true,
+			tmx("", `<group name="a"><group name="b">`+tmxLayerXML("walls", `<data encoding="csv">`+tiledCSV()+"</data>")+`</group>`+tmxPlayer+`</group>`),
+			tiledLevel(nil),
+		},
+		{
+			"tmx objects", true,
+			tmx("", tmxLayerXML("walls", `<data encoding="csv">`+tiledCSV()+"</data>")+`<objectgroup>
+  <object name="player" type="" x="32" y="96"/>
+  <object name="barrel" gid="5" x="64" y="128" width="64" height="64"><properties><property name="sprite" value="barrel.png"/></properties></object>
+  <object name="pillar.png" x="0" y="64" width="64" height="64"/>
+  <object type="exit" x="128" y="64" width="64" height="64"/>
+ </objectgroup>`),
+			tiledLevel(func(lvl *Level) {
+				lvl.Start = Start{Pos: [2]float64{1.5, 0.5}, Angle: math.Pi / 2}
+				lvl.Entities = []Entity{
+					// Tile objects hang up from their position.
+					{Pos: [2]float64{1.5, 1.5}, Sprite: "barrel.png"},
+					{Pos: [2]float64{1.5, 0.5}, Sprite: "pillar.png"},
+				}
+				lvl.Exit = &[2]int{1, 2}
+			}),
+		},
+		{"json array", false, tiledJSON(`{"type": "tilelayer", "name": "walls", "width": 3, "height": 2, "data": [` + tiledCSV() + `]},` + tiledJSONPlayer), tiledLevel(nil)},
+		{"json zlib", false, tiledJSON(`{"type": "tilelayer", "name": "walls", "width": 3, "height": 2, "encoding": "base64", "compression": "zlib", "data": "` + tiledBase64(t, "zlib") + `"},` + tiledJSONPlayer), tiledLevel(nil)},
+		{"json gzip", false, tiledJSON(`{"type": "tilelayer", "name": "walls", "width": 3, "height": 2, "encoding": "base64", "compression": "gzip", "data": "` + tiledBase64(t, "gzip") + `"},` + tiledJSONPlayer), tiledLevel(nil)},
+		{
+			"json nested groups and angle", false,
+			tiledJSON(`{"type": "group", "name": "a", "layers": [{"type": "group", "name": "b", "layers": [
+				{"type": "tilelayer", "name": "floor", "width": 3, "height": 2, "data": [4, 4, 4, 4, 4, 4]},
+				{"type": "tilelayer", "name": "walls", "width": 3, "height": 2, "data": [` + tiledCSV() + `]}]}]},
+				{"type": "objectgroup", "objects": [{"name": "start", "class": "player", "x": 96, "y": 32, "properties": {"angle": 180}}]}`),
+			tiledLevel(func(lvl *Level) {
+				lvl.Floor = floor
+				lvl.Start.Angle = -math.Pi / 2
+			}),
+		},
+	}
+
+	for _, tt := range tests {
+		var (
+			lvl *Level
+			err error
+		)
+		if tt.tmx {
+			lvl, err = readTMX([]byte(tt.data))
+		} else {
+			if !isTiledJSON([]byte(tt.data)) {
+				t.Errorf("%s: not detected as Tiled JSON", tt.name)
+			}
+			lvl, err = ParseLevel([]byte(tt.data))
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(lvl, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, lvl, tt.want)
+		}
+	}
+}
+
+func TestReadTiledErrors(t *testing.T) {
+	walls := tmxLayerXML("walls", `<data encoding="csv">`+tiledCSV()+"</data>")
+
+	tests := []struct {
+		name, data, err string
+	}{
+		{"infinite", tmx(`infinite="1"`, walls+tmxPlayer), "infinite"},
+		{"no walls", tmx("", tmxLayerXML("floor", `<data encoding="csv">4,4,4,4,4,4</data>`)+tmxPlayer), "no tile layer"},
+		{"no player", tmx("", walls), "no player"},
+		{"tile count", tmx("", tmxLayerXML("walls", `<data encoding="csv">1,2,3</data>`)+tmxPlayer), "expected 6 tiles"},
+		{"bad csv", tmx("", tmxLayerXML("walls", `<data encoding="csv">1,x,3,4,5,6</data>`)+tmxPlayer), "invalid syntax"},
+		{"compression", tmx("", tmxLayerXML("walls", `<data encoding="base64" compression="zstd">`+tiledBase64(t, "")+`</data>`)+tmxPlayer), "unsupported compression"},
+		{"encoding", tmx("", tmxLayerXML("walls", `<data encoding="hex">00</data>`)+tmxPlayer), "unsupported encoding"},
+		{"short data", tmx("", tmxLayerXML("walls", `<data encoding="base64">AAAA</data>`)+tmxPlayer), "EOF"},
+	}
+
+	for _, tt := range tests {
+		_, err := readTMX([]byte(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want one about %q", tt.name, err, tt.err)
+		}
+	}
+
+	if _, err := ParseLevel([]byte(tiledJSON(`{"type": "tilelayer", "name": "walls", "width": 3, "height": 2, "data": [1, 2]}`))); err == nil {
+		t.Error("json: expected an error for a short layer")
+	}
+}
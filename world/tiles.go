@@ -0,0 +1,137 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+)
+
+// TileFlags are the triggers of a tile.
+type TileFlags uint8
+
+const (
+	// TriggerExit ends the level when the player enters the tile.
+	TriggerExit TileFlags = 1 << iota
+	// TriggerDoor makes the tile a door that can be opened.
+	TriggerDoor
+	// TriggerSecret marks a secret passage.
+	TriggerSecret
+)
+
+var tileFlagNames = []string{"exit", "door", "secret"}
+
+func (f TileFlags) MarshalJSON() ([]byte, error) {
+	names := []string{}
+	for i, name := range tileFlagNames {
+		if f&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return json.Marshal(names)
+}
+
+func (f *TileFlags) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+
+	*f = 0
+next:
+	for _, name := range names {
+		for i, n := range tileFlagNames {
+			if name == n {
+				*f |= 1 << uint(i)
+				continue next
+			}
+		}
+		return fmt.Errorf("unknown trigger: %s", name)
+	}
+	return nil
+}
+
+// TileDef describes how a type of tile behaves. Damage is taken per second while
+// standing in the tile and Material selects the footstep sounds.
+type TileDef struct {
+	Solid       bool
+	BlocksSight bool
+	Damage      float64   `json:",omitempty"`
+	Material    string    `json:",omitempty"`
+	Triggers    TileFlags `json:",omitempty"`
+}
+
+// TileDefs holds the definitions of tile types that differ from the defaults.
+// Tile 0 is empty floor and every other tile is a solid wall, unless the table says otherwise.
+type TileDefs map[int]TileDef
+
+var (
+	emptyTileDef = TileDef{Material: "stone"}
+	wallTileDef  = TileDef{Solid: true, BlocksSight: true, Material: "stone"}
+)
+
+// Get returns the definition of tile t.
+func (d TileDefs) Get(t int) TileDef {
+	if def, ok := d[t]; ok {
+		return def
+	}
+	if t == 0 {
+		return emptyTileDef
+	}
+	return wallTileDef
+}
+
+// Passable reports if tile t can be walked through, now or when a door is opened.
+func (d TileDefs) Passable(t int) bool {
+	def := d.Get(t)
+	return !def.Solid || def.Triggers&TriggerDoor != 0
+}
+
+// LoadTileDefs reads the tile table from textures/tiles.json in the assets. Fields that
+// are left out of an entry keep their default. Without a table all tiles have defaults.
+func LoadTileDefs() (TileDefs, error) {
+	data, err := fs.ReadFile(assets, "textures/tiles.json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return TileDefs{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("tiles.json: %v", err)
+	}
+
+	defs := make(TileDefs, len(raw))
+	for key, msg := range raw {
+		t, err := strconv.Atoi(key)
+		if err != nil || t < 0 {
+			return nil, fmt.Errorf("tiles.json: invalid tile: %s", key)
+		}
+
+		def := defs.Get(t)
+		if err := json.Unmarshal(msg, &def); err != nil {
+			return nil, fmt.Errorf("tiles.json: tile %d: %v", t, err)
+		}
+		defs[t] = def
+	}
+	return defs, nil
+}
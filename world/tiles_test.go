@@ -0,0 +1,141 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+)
+
+func loadTileDefs(t *testing.T, table string) (world.TileDefs, error) {
+	old := world.Assets()
+	fsys := fstest.MapFS{}
+	if table != "" {
+		fsys["textures/tiles.json"] = &fstest.MapFile{Data: []byte(table)}
+	}
+	world.SetAssets(fsys)
+	defer world.SetAssets(old)
+
+	return world.LoadTileDefs()
+}
+
+func TestLoadTileDefs(t *testing.T) {
+	defs, err := loadTileDefs(t, `{
+		"0": {"Material": "carpet"},
+		"3": {"Damage": 5},
+		"7": {"Solid": false, "BlocksSight": false},
+		"9": {"Triggers": ["door", "secret"]},
+		"12": {"Triggers": ["exit"], "Solid": false}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wall := world.TileDef{Solid: true, BlocksSight: true, Material: "stone"}
+	tests := []struct {
+		tile     int
+		want     world.TileDef
+		passable bool
+	}{
+		// Fields that are left out keep the default of the tile.
+		{0, world.TileDef{Material: "carpet"}, true},
+		{3, world.TileDef{Solid: true, BlocksSight: true, Damage: 5, Material: "stone"}, false},
+		{7, world.TileDef{Material: "stone"}, true},
+		{9, world.TileDef{Solid: true, BlocksSight: true, Material: "stone", Triggers: world.TriggerDoor | world.TriggerSecret}, true},
+		{12, world.TileDef{BlocksSight: true, Material: "stone", Triggers: world.TriggerExit}, true},
+		{1, wall, false},
+		{100, wall, false},
+	}
+
+	for _, tt := range tests {
+		if got := defs.Get(tt.tile); got != tt.want {
+			t.Errorf("tile %d: got %+v, want %+v", tt.tile, got, tt.want)
+		}
+		if got := defs.Passable(tt.tile); got != tt.passable {
+			t.Errorf("tile %d: passable is %v, want %v", tt.tile, got, tt.passable)
+		}
+	}
+
+	if defs, err := loadTileDefs(t, ""); err != nil || len(defs) != 0 {
+		t.Errorf("without a table: got %v, %v", defs, err)
+	}
+}
+
+func TestLoadTileDefsErrors(t *testing.T) {
+	tests := []struct {
+		name, table, err string
+	}{
+		{"unknown trigger", `{"9": {"Triggers": ["door", "teleport"]}}`, "unknown trigger: teleport"},
+		{"invalid key", `{"wall": {"Solid": true}}`, "invalid tile: wall"},
+		{"negative key", `{"-1": {"Solid": true}}`, "invalid tile: -1"},
+		{"bad field", `{"3": {"Damage": "lots"}}`, "tile 3"},
+		{"triggers not a list", `{"3": {"Triggers": "door"}}`, "tile 3"},
+		{"not an object", `[]`, "tiles.json"},
+	}
+
+	for _, tt := range tests {
+		_, err := loadTileDefs(t, tt.table)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want one about %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestTileFlagsJSON(t *testing.T) {
+	tests := []struct {
+		flags world.TileFlags
+		json  string
+	}{
+		{0, `[]`},
+		{world.TriggerExit, `["exit"]`},
+		{world.TriggerDoor | world.TriggerSecret, `["door","secret"]`},
+		{world.TriggerExit | world.TriggerDoor | world.TriggerSecret, `["exit","door","secret"]`},
+	}
+
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.flags)
+		if err != nil || string(data) != tt.json {
+			t.Errorf("%d: marshaled to %s, %v, want %s", tt.flags, data, err, tt.json)
+		}
+
+		flags := world.TriggerExit
+		if err := json.Unmarshal(data, &flags); err != nil || flags != tt.flags {
+			t.Errorf("%s: unmarshaled to %d, %v, want %d", data, flags, err, tt.flags)
+		}
+	}
+
+	// Empty triggers are left out of a definition and read back as none.
+	def := world.TileDef{Solid: true, Material: "metal"}
+	data, err := json.Marshal(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Solid":true,"BlocksSight":false,"Material":"metal"}`; string(data) != want {
+		t.Errorf("marshaled to %s, want %s", data, want)
+	}
+
+	var got world.TileDef
+	if err := json.Unmarshal(data, &got); err != nil || !reflect.DeepEqual(got, def) {
+		t.Errorf("round trip gave %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,250 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+)
+
+// Problem is an issue found by Validate. X and Y are the tile the problem is at,
+// or -1 when it is not tied to a tile.
+type Problem struct {
+	X, Y    int
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.X < 0 || p.Y < 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("tile %d,%d: %s", p.X, p.Y, p.Message)
+}
+
+// Validate checks a level for mistakes that would break it or make it unplayable.
+// Textures and sprites are looked up in the assets. Solid sprites that leave no room to
+// pass count as walls when checking what the player can reach.
+func Validate(lvl *Level) []Problem {
+	var problems []Problem
+	report := func(x, y int, format string, a ...interface{}) {
+		problems = append(problems, Problem{x, y, fmt.Sprintf(format, a...)})
+	}
+
+	textureList, err := loadTextureList()
+	if err != nil {
+		report(-1, -1, "could not read texture list: %v", err)
+	}
+	for _, t := range textureList {
+		if !fileExists(path.Join("textures", t)) {
+			report(-1, -1, "missing texture: %s", t)
+		}
+	}
+	defs, err := LoadTileDefs()
+	if err != nil {
+		report(-1, -1, "could not read tile table: %v", err)
+		defs = TileDefs{}
+	}
+	spriteDefs, err := LoadSpriteDefs()
+	if err != nil {
+		report(-1, -1, "could not read sprite table: %v", err)
+		spriteDefs = SpriteDefs{}
+	}
+	solid := func(x, y int) bool {
+		return defs.Get(lvl.Tiles[x][y]).Solid
+	}
+
+	if lvl.Sky.Texture != "" && !fileExists(path.Join("textures", lvl.Sky.Texture)) {
+		report(-1, -1, "missing sky texture: %s", lvl.Sky.Texture)
+	}
+
+	if lvl.Chunks != nil && len(lvl.Tiles) == 0 {
+		c := lvl.Copy()
+		if err := c.LoadTiles(); err != nil {
+			report(-1, -1, "could not read map chunks: %v", err)
+			return problems
+		}
+		lvl = c
+	}
+
+	if len(lvl.Tiles) == 0 || len(lvl.Tiles[0]) == 0 {
+		report(-1, -1, "level has no tiles")
+		return problems
+	}
+
+	height, width := len(lvl.Tiles), len(lvl.Tiles[0])
+	inside := func(x, y int) bool {
+		return x >= 0 && x < height && y >= 0 && y < len(lvl.Tiles[x])
+	}
+
+	for _, layer := range []struct {
+		name  string
+		tiles [][]int
+	}{{"tiles", lvl.Tiles}, {"floor", lvl.Floor}, {"ceiling", lvl.Ceiling}} {
+		if len(layer.tiles) == 0 {
+			continue
+		}
+		if len(layer.tiles) != height {
+			report(-1, -1, "%s layer has %d rows, expected %d", layer.name, len(layer.tiles), height)
+		}
+
+		for x, row := range layer.tiles {
+			if len(row) != width {
+				report(x, 0, "%s row has %d tiles, expected %d", layer.name, len(row), width)
+			}
+			for y, t := range row {
+				if t < 0 || (textureList != nil && t > len(textureList)) {
+					report(x, y, "%s index %d has no texture, there are %d", layer.name, t, len(textureList))
+				}
+			}
+		}
+	}
+
+	// The ray caster expects the level to be walled in.
+	for x, row := range lvl.Tiles {
+		for y, t := range row {
+			if defs.Passable(t) && (x == 0 || x == height-1 || y == 0 || y == len(row)-1) {
+				report(x, y, "border is open")
+			}
+		}
+	}
+
+	for _, e := range lvl.Entities {
+		x, y := int(math.Floor(e.Pos[0])), int(math.Floor(e.Pos[1]))
+		if !inside(x, y) {
+			report(x, y, "sprite %s is outside the map", e.Sprite)
+		} else if solid(x, y) {
+			report(x, y, "sprite %s is inside a wall", e.Sprite)
+		}
+		if !spriteExists(e.Sprite) {
+			report(x, y, "missing sprite: %s", e.Sprite)
+		}
+	}
+
+	sx, sy := int(math.Floor(lvl.Start.Pos[0])), int(math.Floor(lvl.Start.Pos[1]))
+	if !inside(sx, sy) {
+		report(sx, sy, "player start is outside the map")
+		return problems
+	} else if solid(sx, sy) {
+		report(sx, sy, "player start is inside a wall")
+		return problems
+	}
+
+	// A player that starts next to a prop can still walk away from it.
+	blocked := blockedTiles(lvl, spriteDefs)
+	blocked[sx][sy] = false
+
+	reached := floodFill(lvl.Tiles, defs, blocked, sx, sy)
+	if e := lvl.Exit; e != nil {
+		if !inside(e[0], e[1]) || solid(e[0], e[1]) {
+			report(e[0], e[1], "exit is not on an open tile")
+		} else if !reached[e[0]][e[1]] {
+			report(e[0], e[1], "exit can not be reached from the player start")
+		}
+	}
+
+	for x, row := range lvl.Tiles {
+		for y, t := range row {
+			if !defs.Get(t).Solid && !blocked[x][y] && !reached[x][y] {
+				n := 0
+				for i, area := range floodFill(lvl.Tiles, defs, blocked, x, y) {
+					for j, ok := range area {
+						if ok {
+							reached[i][j] = true
+							n++
+						}
+					}
+				}
+				report(x, y, "area of %d tiles can not be reached from the player start", n)
+			}
+		}
+	}
+
+	return problems
+}
+
+// blockedTiles marks the tiles the player can not cross because a solid sprite in
+// them leaves less than a player width free on both axes.
+func blockedTiles(lvl *Level, defs SpriteDefs) [][]bool {
+	blocked := make([][]bool, len(lvl.Tiles))
+	for i, row := range lvl.Tiles {
+		blocked[i] = make([]bool, len(row))
+	}
+
+	free := func(c, r float64) float64 {
+		lo := math.Floor(c)
+		return math.Max(c-r-lo, lo+1-c-r)
+	}
+
+	for _, e := range lvl.Entities {
+		def, ok := defs[e.Sprite]
+		if !ok || !def.Solid {
+			continue
+		}
+		x, y := int(math.Floor(e.Pos[0])), int(math.Floor(e.Pos[1]))
+		if x < 0 || x >= len(blocked) || y < 0 || y >= len(blocked[x]) {
+			continue
+		}
+		if free(e.Pos[0], def.Radius) < 2*PlayerRadius && free(e.Pos[1], def.Radius) < 2*PlayerRadius {
+			blocked[x][y] = true
+		}
+	}
+	return blocked
+}
+
+// floodFill marks the passable tiles connected to x, y that are not blocked. Doors count
+// as open.
+func floodFill(tiles [][]int, defs TileDefs, blocked [][]bool, x, y int) [][]bool {
+	mark := make([][]bool, len(tiles))
+	for i, row := range tiles {
+		mark[i] = make([]bool, len(row))
+	}
+
+	stack := [][2]int{{x, y}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		x, y := p[0], p[1]
+		if x < 0 || x >= len(tiles) || y < 0 || y >= len(tiles[x]) || mark[x][y] || blocked[x][y] || !defs.Passable(tiles[x][y]) {
+			continue
+		}
+
+		mark[x][y] = true
+		stack = append(stack, [2]int{x + 1, y}, [2]int{x - 1, y}, [2]int{x, y + 1}, [2]int{x, y - 1})
+	}
+	return mark
+}
+
+// spriteExists reports if a sprite file is in the assets. Frames of sprite sheets are
+// loaded to check that the sheet has them.
+func spriteExists(name string) bool {
+	name = path.Join("sprites", name)
+	if strings.Contains(name, "#") {
+		_, err := loadImage(name)
+		return err == nil
+	}
+	return fileExists(name)
+}
+
+func fileExists(name string) bool {
+	_, err := fs.Stat(assets, name)
+	return err == nil
+}
@@ -0,0 +1,158 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world_test
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+)
+
+func TestValidate(t *testing.T) {
+	old := world.Assets()
+	world.SetAssets(fstest.MapFS{
+		"textures/textures.json": {Data: []byte(`["wall.png", "wall.png", "wall.png"]`)},
+		"textures/wall.png":      {},
+		"textures/tiles.json":    {Data: []byte(`{"3": {"Triggers": ["door"]}}`)},
+		"sprites.json":           {Data: []byte(`{"pillar.png": {"Solid": true, "Radius": 0.3}, "barrel.png": {"Solid": true, "Radius": 0.3}}`)},
+		"sprites/pillar.png":     {},
+		"sprites/barrel.png":     {},
+		"sprites/light.png":      {},
+	})
+	defer world.SetAssets(old)
+
+	// A room at rows 1-2 and a corridor through the door at 3,2 to a room at row 4.
+	level := func(edit func(lvl *world.Level)) *world.Level {
+		lvl := &world.Level{
+			Start: world.Start{Pos: [2]float64{1.5, 1.5}},
+			Tiles: [][]int{
+				{1, 1, 1, 1, 1},
+				{1, 0, 0, 0, 1},
+				{1, 0, 0, 0, 1},
+				{1, 1, 3, 1, 1},
+				{1, 0, 0, 0, 1},
+				{1, 1, 1, 1, 1},
+			},
+			Exit: &[2]int{4, 3},
+		}
+		edit(lvl)
+		return lvl
+	}
+
+	tests := []struct {
+		name string
+		lvl  *world.Level
+		want []world.Problem
+	}{
+		{"valid", level(func(*world.Level) {}), nil},
+		{
+			"ragged rows",
+			level(func(lvl *world.Level) { lvl.Tiles[2] = []int{1, 0, 0, 1} }),
+			[]world.Problem{{2, 0, "tiles row has 4 tiles, expected 5"}},
+		},
+		{
+			"ragged floor",
+			level(func(lvl *world.Level) { lvl.Floor = [][]int{{1}} }),
+			[]world.Problem{{-1, -1, "floor layer has 1 rows, expected 6"}, {0, 0, "floor row has 1 tiles, expected 5"}},
+		},
+		{
+			"bad tile indices",
+			level(func(lvl *world.Level) { lvl.Tiles[0][1], lvl.Tiles[0][2] = 4, -1 }),
+			[]world.Problem{{0, 1, "tiles index 4 has no texture, there are 3"}, {0, 2, "tiles index -1 has no texture, there are 3"}},
+		},
+		{
+			"open border",
+			level(func(lvl *world.Level) { lvl.Tiles[4][4] = 0 }),
+			[]world.Problem{{4, 4, "border is open"}},
+		},
+		{
+			"door in border",
+			level(func(lvl *world.Level) { lvl.Tiles[5][2] = 3 }),
+			[]world.Problem{{5, 2, "border is open"}},
+		},
+		{
+			"sprites inside walls and outside the map",
+			level(func(lvl *world.Level) {
+				lvl.Entities = []world.Entity{
+					{Pos: [2]float64{0.5, 2.5}, Sprite: "light.png"},
+					{Pos: [2]float64{7.5, 2.5}, Sprite: "light.png"},
+					{Pos: [2]float64{1.5, 2.5}, Sprite: "gone.png"},
+				}
+			}),
+			[]world.Problem{
+				{0, 2, "sprite light.png is inside a wall"},
+				{7, 2, "sprite light.png is outside the map"},
+				{1, 2, "missing sprite: gone.png"},
+			},
+		},
+		{
+			"start inside a wall",
+			level(func(lvl *world.Level) { lvl.Start.Pos = [2]float64{3.5, 1.5} }),
+			[]world.Problem{{3, 1, "player start is inside a wall"}},
+		},
+		{
+			"unreachable area",
+			level(func(lvl *world.Level) { lvl.Tiles[3][2] = 1 }),
+			[]world.Problem{
+				{4, 3, "exit can not be reached from the player start"},
+				{4, 1, "area of 3 tiles can not be reached from the player start"},
+			},
+		},
+		{
+			"pillar in the corridor",
+			level(func(lvl *world.Level) {
+				lvl.Tiles[3][2] = 0
+				lvl.Entities = []world.Entity{{Pos: [2]float64{3.5, 2.5}, Sprite: "pillar.png"}}
+			}),
+			[]world.Problem{
+				{4, 3, "exit can not be reached from the player start"},
+				{4, 1, "area of 3 tiles can not be reached from the player start"},
+			},
+		},
+		{
+			"props that can be walked around",
+			level(func(lvl *world.Level) {
+				lvl.Tiles[3][2] = 0
+				lvl.Entities = []world.Entity{
+					{Pos: [2]float64{1.5, 3.5}, Sprite: "barrel.png"},
+					{Pos: [2]float64{3.5, 2.9}, Sprite: "barrel.png"},
+					{Pos: [2]float64{1.5, 1.5}, Sprite: "pillar.png"},
+				}
+			}),
+			nil,
+		},
+		{
+			"exit in a wall",
+			level(func(lvl *world.Level) { lvl.Exit = &[2]int{5, 3} }),
+			[]world.Problem{{5, 3, "exit is not on an open tile"}},
+		},
+		{
+			"no tiles",
+			level(func(lvl *world.Level) { lvl.Tiles = nil }),
+			[]world.Problem{{-1, -1, "level has no tiles"}},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := world.Validate(tt.lvl); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
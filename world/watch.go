@@ -0,0 +1,93 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// AssetWatcher polls the assets for files that were added, changed or removed.
+type AssetWatcher struct {
+	interval time.Duration
+	last     time.Time
+	files    map[string]fileStamp
+	err      error
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func NewAssetWatcher(interval time.Duration) *AssetWatcher {
+	aw := &AssetWatcher{interval: interval, last: time.Now()}
+	aw.files, _ = scanAssets()
+	return aw
+}
+
+// Poll returns the files that changed since the last scan and drops them from the
+// asset cache. The assets are scanned at most once per interval. The error of a failed
+// scan is returned until a scan succeeds.
+func (aw *AssetWatcher) Poll() ([]string, error) {
+	if time.Since(aw.last) < aw.interval {
+		return nil, aw.err
+	}
+	aw.last = time.Now()
+
+	files, err := scanAssets()
+	if aw.err = err; err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for name, st := range files {
+		if old, ok := aw.files[name]; !ok || old.size != st.size || !old.modTime.Equal(st.modTime) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range aw.files {
+		if _, ok := files[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+
+	aw.files = files
+	forgetAssets(changed)
+
+	sort.Strings(changed)
+	return changed, nil
+}
+
+func scanAssets() (map[string]fileStamp, error) {
+	files := make(map[string]fileStamp)
+	err := fs.WalkDir(assets, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[name] = fileStamp{fi.ModTime(), fi.Size()}
+		return nil
+	})
+	return files, err
+}
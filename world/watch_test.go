@@ -0,0 +1,75 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type brokenFS struct{}
+
+func (brokenFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("device gone")}
+}
+
+func TestAssetWatcher(t *testing.T) {
+	old := assets
+	defer func() { assets = old }()
+
+	files := fstest.MapFS{"maps/level1.json": {Data: []byte("{}")}}
+	assets = files
+
+	aw := NewAssetWatcher(time.Hour)
+	rescan := func() ([]string, error) {
+		aw.last = time.Time{}
+		return aw.Poll()
+	}
+
+	if changed, err := aw.Poll(); changed != nil || err != nil {
+		t.Errorf("poll before the interval: got %v, %v", changed, err)
+	}
+
+	files["maps/level1.json"] = &fstest.MapFile{Data: []byte("{ }")}
+	files["maps/level2.json"] = &fstest.MapFile{}
+	if changed, err := rescan(); err != nil || !reflect.DeepEqual(changed, []string{"maps/level1.json", "maps/level2.json"}) {
+		t.Errorf("got %v, %v", changed, err)
+	}
+
+	// A failed scan is reported until the next scan succeeds.
+	assets = brokenFS{}
+	if _, err := rescan(); err == nil {
+		t.Fatal("expected a scan error")
+	}
+	if _, err := aw.Poll(); err == nil {
+		t.Error("scan error was not kept until the next scan")
+	}
+
+	assets = files
+	delete(files, "maps/level2.json")
+	if changed, err := rescan(); err != nil || !reflect.DeepEqual(changed, []string{"maps/level2.json"}) {
+		t.Errorf("after the error: got %v, %v", changed, err)
+	}
+	if _, err := aw.Poll(); err != nil {
+		t.Errorf("error was kept after a successful scan: %v", err)
+	}
+}
@@ -0,0 +1,547 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/andreas-jonsson/go-wolf/engine"
+	"github.com/ungerik/go3d/float64/vec2"
+)
+
+// Reference: http://www.shikadi.net/moddingwiki/GameMaps_Format
+//            http://www.shikadi.net/moddingwiki/VSWAP_Format
+
+const (
+	wolfMapSize     = 64
+	wolfNumMaps     = 100
+	wolfCarmackNear = 0xa7
+	wolfCarmackFar  = 0xa8
+
+	wolfLastWall     = 63
+	wolfFirstDoor    = 90
+	wolfLastDoor     = 101
+	wolfFirstStart   = 19
+	wolfFirstStatic  = 23
+	wolfLastStatic   = 70
+	wolfSpriteStat0  = 2    // SPR_STAT_0, the first static object sprite.
+	wolfCeilingColor = 0x1d // Ceiling color used by most levels.
+)
+
+// Door textures relative to the first door page in VSWAP.
+const (
+	wolfDoorNormal   = 0
+	wolfDoorElevator = 4
+	wolfDoorLocked   = 6
+)
+
+type wolfMapHeader struct {
+	PlaneStart    [3]int32
+	PlaneLength   [3]uint16
+	Width, Height uint16
+	Name          [16]byte
+}
+
+type wolfData struct {
+	dir, ext string
+	palette  color.Palette
+
+	rlewTag uint16
+	offsets [wolfNumMaps]int32
+	maps    []byte
+
+	spriteStart, soundStart int
+	pages                   [][]byte
+}
+
+// ImportWolf3D reads map number mapNum (counting from zero) from the original Wolfenstein 3D
+// data files in dir. Shareware (WL1) and registered (WL6) files are supported.
+//
+// The game palette is compiled into the Wolfenstein 3D executable, so a copy of it is built in.
+// Another palette can be given as a 768 byte RGB file named GAMEPAL or WOLF.PAL in the same
+// directory. 6-bit VGA values are detected and scaled. Only walls, doors, the player start and
+// static objects are imported.
+func ImportWolf3D(dir string, mapNum int) (*World, engine.SpriteInstances, error) {
+	wd, err := openWolfData(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hdr, planes, err := wd.loadMap(mapNum)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	width, height := int(hdr.Width), int(hdr.Height)
+	w := &World{
+		name:     strings.TrimRight(string(hdr.Name[:]), "\x00"),
+		sky:      Sky{Color: rgbOf(wd.palette[wolfCeilingColor])},
+		tileDefs: make(TileDefs),
+	}
+
+	// Every wall page pair is one texture, followed by the three door types.
+	numWalls := wd.spriteStart / 2
+	doorPage := wd.spriteStart - 8
+	for i := 0; i < numWalls; i++ {
+		w.textures = append(w.textures, wd.wall(i*2))
+		w.shaded = append(w.shaded, wd.wall(i*2+1))
+	}
+	for i, d := range []int{wolfDoorNormal, wolfDoorLocked, wolfDoorElevator} {
+		w.textures = append(w.textures, wd.wall(doorPage+d))
+		w.shaded = append(w.shaded, wd.wall(doorPage+d+1))
+		w.tileDefs[numWalls+1+i] = TileDef{Solid: true, BlocksSight: true, Material: "metal", Triggers: TriggerDoor}
+	}
+
+	// Map X is the row and map Y the column, which keeps the level from being mirrored.
+	tiles := make(gridMap, height)
+	for y := range tiles {
+		tiles[y] = make([]int, width)
+		for x := range tiles[y] {
+			tiles[y][x] = wolfTile(planes[0][y*width+x], numWalls)
+		}
+	}
+	w.tiles = tiles
+
+	var sprites engine.SpriteInstances
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pos := vec2.T{float64(y) + 0.5, float64(x) + 0.5}
+
+			switch obj := int(planes[1][y*width+x]); {
+			case obj >= wolfFirstStart && obj < wolfFirstStart+4:
+				// North, east, south and west.
+				angle := [4]float64{math.Pi, math.Pi / 2, 0, -math.Pi / 2}[obj-wolfFirstStart]
+				w.start = Start{Pos: [2]float64{pos[0], pos[1]}, Angle: angle}
+			case obj >= wolfFirstStatic && obj <= wolfLastStatic:
+				page := wd.spriteStart + wolfSpriteStat0 + obj - wolfFirstStatic
+				if page >= wd.soundStart {
+					continue
+				}
+
+				img, err := wd.sprite(page)
+				if err != nil {
+					return nil, nil, err
+				}
+				sprites = append(sprites, engine.SpriteInstance{Pos: pos, Tex: img})
+			}
+		}
+	}
+
+	return w, sprites, nil
+}
+
+func wolfTile(t uint16, numWalls int) int {
+	switch {
+	case t >= 1 && t <= wolfLastWall && int(t) <= numWalls:
+		return int(t)
+	case t >= wolfFirstDoor && t <= wolfLastDoor:
+		switch t {
+		case 90, 91:
+			return numWalls + 1
+		case 100, 101:
+			return numWalls + 3
+		default:
+			return numWalls + 2
+		}
+	}
+	return 0
+}
+
+func rgbOf(c color.Color) [3]uint8 {
+	r, g, b, _ := c.RGBA()
+	return [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)}
+}
+
+// findWolfFile looks up a data file by name, ignoring case. An empty ext matches any extension.
+func findWolfFile(dir, name, ext string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range entries {
+		n := strings.ToUpper(e.Name())
+		if ext == "" {
+			if n == name || strings.HasPrefix(n, name+".") {
+				return path.Join(dir, e.Name()), nil
+			}
+		} else if n == name+"."+ext {
+			return path.Join(dir, e.Name()), nil
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+func wolfFileName(name, ext string) string {
+	if ext == "" {
+		return name
+	}
+	return name + "." + ext
+}
+
+func openWolfData(dir string) (*wolfData, error) {
+	wd := &wolfData{dir: dir}
+
+	mapHead, err := findWolfFile(dir, "MAPHEAD", "")
+	if err != nil {
+		return nil, fmt.Errorf("%s: no MAPHEAD file found", dir)
+	}
+	// The other files have the same extension, or none like MAPHEAD itself.
+	wd.ext = strings.TrimPrefix(strings.ToUpper(path.Ext(mapHead)), ".")
+
+	data, err := os.ReadFile(mapHead)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2 {
+		return nil, fmt.Errorf("%s: file is too short", mapHead)
+	}
+
+	wd.rlewTag = binary.LittleEndian.Uint16(data)
+	for i := 0; i < wolfNumMaps && 2+i*4+4 <= len(data); i++ {
+		wd.offsets[i] = int32(binary.LittleEndian.Uint32(data[2+i*4:]))
+	}
+
+	gameMaps, err := findWolfFile(dir, "GAMEMAPS", wd.ext)
+	if err != nil {
+		return nil, fmt.Errorf("%s: no %s file found", dir, wolfFileName("GAMEMAPS", wd.ext))
+	}
+	if wd.maps, err = os.ReadFile(gameMaps); err != nil {
+		return nil, err
+	}
+
+	if err := wd.loadPages(); err != nil {
+		return nil, err
+	}
+	if err := wd.loadPalette(); err != nil {
+		return nil, err
+	}
+	return wd, nil
+}
+
+func (wd *wolfData) loadPages() error {
+	name, err := findWolfFile(wd.dir, "VSWAP", wd.ext)
+	if err != nil {
+		return fmt.Errorf("%s: no %s file found", wd.dir, wolfFileName("VSWAP", wd.ext))
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	if len(data) < 6 {
+		return fmt.Errorf("%s: file is too short", name)
+	}
+
+	numChunks := int(binary.LittleEndian.Uint16(data))
+	wd.spriteStart = int(binary.LittleEndian.Uint16(data[2:]))
+	wd.soundStart = int(binary.LittleEndian.Uint16(data[4:]))
+
+	if len(data) < 6+numChunks*6 || wd.spriteStart > wd.soundStart || wd.soundStart > numChunks {
+		return fmt.Errorf("%s: invalid header", name)
+	}
+
+	lengths := data[6+numChunks*4:]
+	for i := 0; i < numChunks; i++ {
+		offset := int(binary.LittleEndian.Uint32(data[6+i*4:]))
+		length := int(binary.LittleEndian.Uint16(lengths[i*2:]))
+
+		if offset+length > len(data) {
+			return fmt.Errorf("%s: chunk %d is out of range", name, i)
+		}
+		wd.pages = append(wd.pages, data[offset:offset+length])
+	}
+	return nil
+}
+
+func (wd *wolfData) loadPalette() error {
+	data := wolfGamePalette[:]
+
+	name, err := findWolfFile(wd.dir, "GAMEPAL", "")
+	if err != nil {
+		name, err = findWolfFile(wd.dir, "WOLF", "PAL")
+	}
+	if err == nil {
+		if data, err = os.ReadFile(name); err != nil {
+			return err
+		}
+		if len(data) < 768 {
+			return fmt.Errorf("%s: palette must be 768 bytes", name)
+		}
+		data = data[:768]
+	}
+
+	vga := true
+	for _, v := range data {
+		if v > 63 {
+			vga = false
+			break
+		}
+	}
+
+	wd.palette = make(color.Palette, 256)
+	for i := range wd.palette {
+		rgb := data[i*3 : i*3+3]
+		c := color.RGBA{rgb[0], rgb[1], rgb[2], 255}
+		if vga {
+			c = color.RGBA{rgb[0]<<2 | rgb[0]>>4, rgb[1]<<2 | rgb[1]>>4, rgb[2]<<2 | rgb[2]>>4, 255}
+		}
+		wd.palette[i] = c
+	}
+	return nil
+}
+
+func (wd *wolfData) loadMap(n int) (*wolfMapHeader, [2][]uint16, error) {
+	var planes [2][]uint16
+	if n < 0 || n >= wolfNumMaps || wd.offsets[n] <= 0 {
+		return nil, planes, fmt.Errorf("map %d does not exist", n)
+	}
+
+	offset := int(wd.offsets[n])
+	if offset+binary.Size(wolfMapHeader{}) > len(wd.maps) {
+		return nil, planes, fmt.Errorf("map %d: header is out of range", n)
+	}
+
+	hdr := new(wolfMapHeader)
+	if err := binary.Read(bytes.NewReader(wd.maps[offset:]), binary.LittleEndian, hdr); err != nil {
+		return nil, planes, err
+	}
+
+	if hdr.Width != wolfMapSize || hdr.Height != wolfMapSize {
+		return nil, planes, fmt.Errorf("map %d: unexpected size %dx%d", n, hdr.Width, hdr.Height)
+	}
+
+	for i := range planes {
+		start, length := int(hdr.PlaneStart[i]), int(hdr.PlaneLength[i])
+		if start < 0 || start+length > len(wd.maps) {
+			return nil, planes, fmt.Errorf("map %d: plane %d is out of range", n, i)
+		}
+
+		carmack, err := carmackExpand(wd.maps[start : start+length])
+		if err != nil {
+			return nil, planes, fmt.Errorf("map %d: plane %d: %v", n, i, err)
+		}
+
+		if planes[i], err = rlewExpand(carmack, wd.rlewTag); err != nil {
+			return nil, planes, fmt.Errorf("map %d: plane %d: %v", n, i, err)
+		}
+
+		if len(planes[i]) < wolfMapSize*wolfMapSize {
+			return nil, planes, fmt.Errorf("map %d: plane %d is too short", n, i)
+		}
+	}
+	return hdr, planes, nil
+}
+
+var errWolfTruncated = errors.New("compressed data is truncated")
+
+// carmackExpand decompresses Carmack compressed data. The first word holds the expanded size in bytes.
+func carmackExpand(src []byte) ([]uint16, error) {
+	if len(src) < 2 {
+		return nil, errWolfTruncated
+	}
+
+	size := int(binary.LittleEndian.Uint16(src)) / 2
+	src = src[2:]
+	dst := make([]uint16, 0, size)
+
+	readByte := func() (int, error) {
+		if len(src) < 1 {
+			return 0, errWolfTruncated
+		}
+		b := src[0]
+		src = src[1:]
+		return int(b), nil
+	}
+
+	readWord := func() (int, error) {
+		if len(src) < 2 {
+			return 0, errWolfTruncated
+		}
+		w := binary.LittleEndian.Uint16(src)
+		src = src[2:]
+		return int(w), nil
+	}
+
+	for len(dst) < size {
+		ch, err := readWord()
+		if err != nil {
+			return nil, err
+		}
+
+		tag, count := ch>>8, ch&0xff
+		if tag != wolfCarmackNear && tag != wolfCarmackFar {
+			dst = append(dst, uint16(ch))
+			continue
+		}
+
+		if count == 0 {
+			// A literal word that happens to look like a tag. The low byte follows.
+			low, err := readByte()
+			if err != nil {
+				return nil, err
+			}
+			dst = append(dst, uint16(ch|low))
+			continue
+		}
+
+		var from int
+		if tag == wolfCarmackNear {
+			offset, err := readByte()
+			if err != nil {
+				return nil, err
+			}
+			from = len(dst) - offset
+		} else {
+			if from, err = readWord(); err != nil {
+				return nil, err
+			}
+		}
+
+		if from < 0 || from >= len(dst) {
+			return nil, errors.New("invalid carmack back reference")
+		}
+
+		// The source may overlap with what is being written, so copy one word at a time.
+		for i := 0; i < count; i++ {
+			dst = append(dst, dst[from+i])
+		}
+	}
+	return dst, nil
+}
+
+// rlewExpand expands run length encoded words. The first word holds the expanded size in bytes.
+func rlewExpand(src []uint16, tag uint16) ([]uint16, error) {
+	if len(src) < 1 {
+		return nil, errWolfTruncated
+	}
+
+	size := int(src[0]) / 2
+	src = src[1:]
+	dst := make([]uint16, 0, size)
+
+	for len(dst) < size {
+		if len(src) < 1 {
+			return nil, errWolfTruncated
+		}
+
+		if src[0] != tag {
+			dst = append(dst, src[0])
+			src = src[1:]
+			continue
+		}
+
+		if len(src) < 3 {
+			return nil, errWolfTruncated
+		}
+
+		count, value := int(src[1]), src[2]
+		src = src[3:]
+		for i := 0; i < count; i++ {
+			dst = append(dst, value)
+		}
+	}
+	return dst, nil
+}
+
+// wall decodes a 64x64 wall page. Pixels are stored column by column.
+func (wd *wolfData) wall(page int) image.Image {
+	img := image.NewPaletted(image.Rect(0, 0, wolfMapSize, wolfMapSize), wd.palette)
+	if page < 0 || page >= len(wd.pages) {
+		return img
+	}
+
+	data := wd.pages[page]
+	for x := 0; x < wolfMapSize; x++ {
+		for y := 0; y < wolfMapSize && x*wolfMapSize+y < len(data); y++ {
+			img.Pix[y*img.Stride+x] = data[x*wolfMapSize+y]
+		}
+	}
+	return img
+}
+
+// sprite decodes a compressed sprite page. Transparent pixels are black, like the other sprites.
+func (wd *wolfData) sprite(page int) (image.Image, error) {
+	img := image.NewRGBA(image.Rect(0, 0, wolfMapSize, wolfMapSize))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i+3] = 255
+	}
+
+	data := wd.pages[page]
+	word := func(i int) (int, error) {
+		if i < 0 || i+2 > len(data) {
+			return 0, fmt.Errorf("sprite %d is truncated", page)
+		}
+		return int(binary.LittleEndian.Uint16(data[i:])), nil
+	}
+
+	left, err := word(0)
+	if err != nil {
+		return nil, err
+	}
+	right, err := word(2)
+	if err != nil {
+		return nil, err
+	}
+
+	for x := left; x <= right && x < wolfMapSize; x++ {
+		cmd, err := word(4 + (x-left)*2)
+		if err != nil {
+			return nil, err
+		}
+
+		for {
+			end, err := word(cmd)
+			if err != nil {
+				return nil, err
+			}
+			if end == 0 {
+				break
+			}
+
+			src, err := word(cmd + 2)
+			if err != nil {
+				return nil, err
+			}
+			start, err := word(cmd + 4)
+			if err != nil {
+				return nil, err
+			}
+
+			for y := start / 2; y < end/2 && y < wolfMapSize; y++ {
+				i := int(int16(src)) + y
+				if i < 0 || i >= len(data) {
+					return nil, fmt.Errorf("sprite %d is truncated", page)
+				}
+				img.Set(x, y, wd.palette[data[i]])
+			}
+			cmd += 6
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,170 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCarmackExpand(t *testing.T) {
+	tests := []struct {
+		name string
+		src  []byte
+		want []uint16
+		err  bool
+	}{
+		{"plain words", []byte{4, 0, 0x34, 0x12, 0x78, 0x56}, []uint16{0x1234, 0x5678}, false},
+		{"near tag literal", []byte{2, 0, 0x00, 0xa7, 0x12}, []uint16{0xa712}, false},
+		{"far tag literal", []byte{2, 0, 0x00, 0xa8, 0x34}, []uint16{0xa834}, false},
+		{"near copy", []byte{10, 0, 1, 0, 2, 0, 3, 0, 0x02, 0xa7, 3}, []uint16{1, 2, 3, 1, 2}, false},
+		{"near copy overlapping", []byte{8, 0, 5, 0, 0x03, 0xa7, 1}, []uint16{5, 5, 5, 5}, false},
+		{"far copy", []byte{10, 0, 1, 0, 2, 0, 0x03, 0xa8, 0, 0}, []uint16{1, 2, 1, 2, 1}, false},
+		{"empty", []byte{0, 0}, []uint16{}, false},
+		{"no size", []byte{4}, nil, true},
+		{"truncated word", []byte{4, 0, 0x34, 0x12, 0x78}, nil, true},
+		{"truncated literal", []byte{2, 0, 0x00, 0xa7}, nil, true},
+		{"truncated near pointer", []byte{4, 0, 1, 0, 0x01, 0xa7}, nil, true},
+		{"truncated far pointer", []byte{4, 0, 1, 0, 0x01, 0xa8, 0}, nil, true},
+		{"near pointer before start", []byte{4, 0, 1, 0, 0x01, 0xa7, 2}, nil, true},
+		{"far pointer past end", []byte{4, 0, 1, 0, 0x01, 0xa8, 1, 0}, nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := carmackExpand(tt.src)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %x, want %x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRLEWExpand(t *testing.T) {
+	const tag = 0xabcd
+
+	tests := []struct {
+		name string
+		src  []uint16
+		want []uint16
+		err  bool
+	}{
+		{"plain words", []uint16{6, 1, 2, 3}, []uint16{1, 2, 3}, false},
+		{"run", []uint16{10, 1, tag, 3, 7, 2}, []uint16{1, 7, 7, 7, 2}, false},
+		{"empty run", []uint16{4, tag, 0, 7, 1, 2}, []uint16{1, 2}, false},
+		{"empty", []uint16{0}, []uint16{}, false},
+		{"no size", nil, nil, true},
+		{"truncated", []uint16{6, 1, 2}, nil, true},
+		{"truncated run", []uint16{6, tag, 3}, nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := rlewExpand(tt.src, tag)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %x, want %x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOpenWolfDataWithoutExtension(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"MAPHEAD":  {0xcd, 0xab},
+		"GAMEMAPS": []byte("TED5v1.0"),
+		"VSWAP":    make([]byte, 6),
+		"GAMEPAL":  make([]byte, 768),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := openWolfData(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wd.ext != "" || wd.rlewTag != 0xabcd {
+		t.Errorf("got extension %q and tag %x", wd.ext, wd.rlewTag)
+	}
+
+	os.Remove(filepath.Join(dir, "VSWAP"))
+	if _, err := openWolfData(dir); err == nil {
+		t.Error("expected an error without VSWAP")
+	}
+}
+
+func TestWolfPalette(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"MAPHEAD.WL1":  {0xcd, 0xab},
+		"GAMEMAPS.WL1": []byte("TED5v1.0"),
+		"VSWAP.WL1":    make([]byte, 6),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Stock data has no palette file, so the built in one is used.
+	wd, err := openWolfData(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range map[int]color.RGBA{
+		0:                {0, 0, 0, 255},
+		wolfCeilingColor: {56, 56, 56, 255},
+		0x19:             {113, 113, 113, 255},
+		0xff:             {154, 0, 138, 255},
+	} {
+		if got := wd.palette[i]; got != want {
+			t.Errorf("color %#x is %v, want %v", i, got, want)
+		}
+	}
+
+	// A palette file with 8-bit values replaces it.
+	pal := make([]byte, 768)
+	pal[3], pal[4], pal[5] = 200, 100, 0
+	if err := os.WriteFile(filepath.Join(dir, "wolf.pal"), pal, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if wd, err = openWolfData(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := wd.palette[1], (color.RGBA{200, 100, 0, 255}); got != want {
+		t.Errorf("color 1 is %v, want %v", got, want)
+	}
+}
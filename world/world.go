@@ -20,8 +20,6 @@ package world
 import (
 	"encoding/json"
 	"image"
-	"image/png"
-	"os"
 	"path"
 
 	"github.com/andreas-jonsson/go-wolf/engine"
@@ -29,27 +27,80 @@ import (
 )
 
 type World struct {
-	mapData  [][]int
+	tiles    tileMap
+	floor    [][]int
+	ceiling  [][]int
 	textures []image.Image
+	shaded   []image.Image
+	tileDefs TileDefs
+
+	spriteDefs SpriteDefs
+
+	openDoors map[[2]int]bool
+	zoneCache *zones
+
+	observers    []tileObserver
+	nextObserver int
+
+	entities    []Entity
+	name, music string
+	sky         Sky
+	start       Start
+	exit        *[2]int
 }
 
 func NewWorld(name string) (*World, error) {
-	w := new(World)
+	lvl, err := LoadLevel(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewWorldFromLevel(lvl)
+}
+
+// NewWorldFromLevel builds a world from a copy of lvl, so changes to the world do not change lvl.
+func NewWorldFromLevel(lvl *Level) (*World, error) {
+	lvl = lvl.Copy()
+	w := &World{
+		tiles:    gridMap(lvl.Tiles),
+		floor:    lvl.Floor,
+		ceiling:  lvl.Ceiling,
+		entities: lvl.Entities,
+		name:     lvl.Name,
+		music:    lvl.Music,
+		sky:      lvl.Sky,
+		start:    lvl.Start,
+		exit:     lvl.Exit,
+	}
+
+	if lvl.Chunks != nil && len(lvl.Tiles) == 0 {
+		m, err := newChunkMap(assets, *lvl.Chunks)
+		if err != nil {
+			return nil, err
+		}
+		w.tiles = m
+	}
+
 	if err := w.loadTextures(); err != nil {
 		return nil, err
 	}
 
-	if err := w.loadMap(name); err != nil {
+	defs, err := LoadTileDefs()
+	if err != nil {
+		return nil, err
+	}
+	w.tileDefs = defs
+
+	if w.spriteDefs, err = LoadSpriteDefs(); err != nil {
 		return nil, err
 	}
 
 	return w, nil
 }
 
-func (w *World) loadTextures() error {
-	fp, err := os.Open(path.Join("data", "textures", "textures.json"))
+func loadTextureList() ([]string, error) {
+	fp, err := assets.Open("textures/textures.json")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer fp.Close()
 
@@ -57,81 +108,232 @@ func (w *World) loadTextures() error {
 
 	dec := json.NewDecoder(fp)
 	if err := dec.Decode(&textureList); err != nil {
+		return nil, err
+	}
+	return textureList, nil
+}
+
+func (w *World) loadTextures() error {
+	textureList, err := loadTextureList()
+	if err != nil {
 		return err
 	}
 
 	for _, t := range textureList {
-		fp, err := os.Open(path.Join("data", "textures", t))
+		img, err := loadImage(path.Join("textures", t))
 		if err != nil {
 			return err
 		}
+		w.textures = append(w.textures, img)
+	}
 
-		img, err := png.Decode(fp)
-		fp.Close()
+	return nil
+}
 
-		if err != nil {
-			return err
-		}
+func (w *World) GetTexture(index, shade int) engine.Texture {
+	if shade > 0 && index < len(w.shaded) {
+		return w.shaded[index]
+	}
+	return w.textures[index]
+}
 
-		w.textures = append(w.textures, img)
+// NumTextures returns the number of wall textures. Tile N uses texture N-1.
+func (w *World) NumTextures() int {
+	return len(w.textures)
+}
+
+// Size returns the number of rows and columns in the tile grid.
+func (w *World) Size() (rows, cols int) {
+	return w.tiles.size()
+}
+
+// GetTile returns the tile at x, y. Open doors and tiles outside the map read as empty,
+// so the ray caster sees through open doors.
+func (w *World) GetTile(x, y int) int {
+	if !w.inside(x, y) || w.openDoors[[2]int{x, y}] {
+		return 0
 	}
+	return w.tiles.tile(x, y)
+}
 
-	return nil
+// SetTile changes the tile at x, y and notifies the observers. A door that is replaced
+// by another tile is closed. Tiles outside the map can not be changed.
+func (w *World) SetTile(x, y, tile int) {
+	if !w.inside(x, y) {
+		return
+	}
+	old, oldDef := w.GetTile(x, y), w.TileDef(x, y)
+	wasOpen := w.DoorOpen(x, y)
+
+	w.tiles.setTile(x, y, tile)
+	delete(w.openDoors, [2]int{x, y})
+
+	// Zones only change with what can be walked through.
+	newDef := w.TileDef(x, y)
+	if wasOpen || oldDef.Solid != newDef.Solid || oldDef.Triggers&TriggerDoor != newDef.Triggers&TriggerDoor {
+		w.zoneCache = nil
+	}
+
+	w.notify(TileChange{x, y, old, tile})
 }
 
-func (w *World) loadMap(name string) error {
-	fp, err := os.Open(path.Join("data", "maps", name+".json"))
-	if err != nil {
-		return err
+// TileDefs returns the tile table of the world.
+func (w *World) TileDefs() TileDefs {
+	return w.tileDefs
+}
+
+// TileDef returns the definition of the tile at x, y. Tiles outside the map are solid walls.
+func (w *World) TileDef(x, y int) TileDef {
+	if !w.inside(x, y) {
+		return wallTileDef
 	}
-	defer fp.Close()
+	return w.tileDefs.Get(w.tiles.tile(x, y))
+}
 
-	dec := json.NewDecoder(fp)
-	if err := dec.Decode(&w.mapData); err != nil {
-		return err
+func (w *World) inside(x, y int) bool {
+	rows, cols := w.tiles.size()
+	return x >= 0 && x < rows && y >= 0 && y < cols
+}
+
+// IsSolid reports if the tile at x, y blocks movement. Open doors do not.
+func (w *World) IsSolid(x, y int) bool {
+	return w.TileDef(x, y).Solid && !w.DoorOpen(x, y)
+}
+
+// BlocksSight reports if the tile at x, y blocks line of sight. Open doors do not.
+func (w *World) BlocksSight(x, y int) bool {
+	return w.TileDef(x, y).BlocksSight && !w.DoorOpen(x, y)
+}
+
+// GetFloor returns the floor layer tile at x, y, or zero if the level has no floor layer.
+func (w *World) GetFloor(x, y int) int {
+	return layerTile(w.floor, x, y)
+}
+
+// GetCeiling returns the ceiling layer tile at x, y, or zero if the level has no ceiling layer.
+func (w *World) GetCeiling(x, y int) int {
+	return layerTile(w.ceiling, x, y)
+}
+
+func layerTile(layer [][]int, x, y int) int {
+	if x < 0 || x >= len(layer) || y < 0 || y >= len(layer[x]) {
+		return 0
 	}
+	return layer[x][y]
+}
 
-	return nil
+func (w *World) Name() string {
+	return w.name
 }
 
-func (w *World) GetTexture(index, shade int) engine.Texture {
-	// Ignore shades for now...
-	return w.textures[index]
+func (w *World) Music() string {
+	return w.music
 }
 
-func (w *World) GetTile(x, y int) int {
-	return w.mapData[x][y]
+func (w *World) Sky() Sky {
+	return w.sky
 }
 
-func LoadSprites(name string) (engine.SpriteInstances, error) {
-	fp, err := os.Open(path.Join("data", "sprites", name+".json"))
-	if err != nil {
-		return nil, err
+// Start returns the player start position and view angle.
+func (w *World) Start() (vec2.T, float64) {
+	return vec2.T{w.start.Pos[0], w.start.Pos[1]}, w.start.Angle
+}
+
+// SetStart moves the player start.
+func (w *World) SetStart(pos vec2.T, angle float64) {
+	w.start = Start{Pos: [2]float64{pos[0], pos[1]}, Angle: angle}
+}
+
+// Entities returns the sprites placed in the world. The slice must not be modified,
+// use AddSprite and RemoveSprite instead.
+func (w *World) Entities() []Entity {
+	return w.entities
+}
+
+// AddSprite places a sprite in the world and returns its index.
+func (w *World) AddSprite(e Entity) int {
+	w.entities = append(w.entities, e)
+	return len(w.entities) - 1
+}
+
+// RemoveSprite removes the sprite at index i and returns it.
+func (w *World) RemoveSprite(i int) Entity {
+	e := w.entities[i]
+	w.entities = append(w.entities[:i:i], w.entities[i+1:]...)
+	return e
+}
+
+// SpriteAt returns the index of the first sprite in tile x, y, or -1 if there is none.
+func (w *World) SpriteAt(x, y int) int {
+	for i, e := range w.entities {
+		if int(e.Pos[0]) == x && int(e.Pos[1]) == y {
+			return i
+		}
 	}
-	defer fp.Close()
+	return -1
+}
+
+// Sprites loads the sprite instances the engine draws for the entities in the world.
+func (w *World) Sprites() (engine.SpriteInstances, error) {
+	return loadEntitySprites(w.entities)
+}
 
+// Level returns the world in the level format. Worlds imported from Wolfenstein 3D
+// data have their sprites outside the world, so they are not included. Chunked
+// worlds return their Chunks without Tiles, use SaveWorld to write their changes.
+func (w *World) Level() *Level {
 	var (
-		instances  engine.SpriteInstances
-		spriteList []struct {
-			Pos    [2]float64
-			Sprite string
-		}
+		tiles  gridMap
+		chunks *Chunks
 	)
+	switch m := w.tiles.(type) {
+	case gridMap:
+		tiles = m
+	case *chunkMap:
+		hdr := m.hdr
+		chunks = &hdr
+	}
 
-	dec := json.NewDecoder(fp)
-	if err := dec.Decode(&spriteList); err != nil {
-		return nil, err
+	lvl := &Level{
+		Version:  LevelVersion,
+		Name:     w.name,
+		Music:    w.music,
+		Sky:      w.sky,
+		Start:    w.start,
+		Exit:     w.exit,
+		Chunks:   chunks,
+		Tiles:    tiles,
+		Floor:    w.floor,
+		Ceiling:  w.ceiling,
+		Entities: w.entities,
 	}
+	return lvl.Copy()
+}
 
-	for _, s := range spriteList {
-		fp, err := os.Open(path.Join("data", "sprites", s.Sprite))
-		if err != nil {
-			return nil, err
-		}
+// Exit returns the tile that ends the level. The level has no exit if ok is false.
+func (w *World) Exit() (x, y int, ok bool) {
+	if w.exit == nil {
+		return 0, 0, false
+	}
+	return w.exit[0], w.exit[1], true
+}
 
-		img, err := png.Decode(fp)
-		fp.Close()
+func LoadSprites(name string) (engine.SpriteInstances, error) {
+	lvl, err := LoadLevel(name)
+	if err != nil {
+		return nil, err
+	}
+	return LoadLevelSprites(lvl)
+}
+
+func LoadLevelSprites(lvl *Level) (engine.SpriteInstances, error) {
+	return loadEntitySprites(lvl.Entities)
+}
 
+func loadEntitySprites(entities []Entity) (engine.SpriteInstances, error) {
+	var instances engine.SpriteInstances
+	for _, s := range entities {
+		img, err := loadImage(path.Join("sprites", s.Sprite))
 		if err != nil {
 			return nil, err
 		}
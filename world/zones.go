@@ -0,0 +1,285 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world
+
+import (
+	"math"
+
+	"github.com/ungerik/go3d/float64/vec2"
+)
+
+// zoneBlockSize is the width and height of the blocks zones are stored in.
+const zoneBlockSize = 64
+
+// zones divides the map into areas of open tiles that are separated by doors, like
+// the areas Wolfenstein 3D uses to decide which enemies hear a gunshot.
+type zones struct {
+	rows, cols int
+	// blocks holds the zone of every tile in blocks of zoneBlockSize by zoneBlockSize,
+	// -1 for walls and doors. Blocks without open tiles are left out.
+	blocks map[[2]int][]int32
+	// doors maps a door tile to the zones on its sides.
+	doors map[[2]int][]int
+	// group numbers the zones, zones in the same group are connected through open doors.
+	group []int
+}
+
+// computeZones flood fills the open tiles of w into zones and links them through its doors.
+// It reads every tile, so all chunks of a chunked map are loaded on the way.
+func computeZones(w *World) *zones {
+	rows, cols := w.tiles.size()
+	z := &zones{
+		rows:   rows,
+		cols:   cols,
+		blocks: make(map[[2]int][]int32),
+		doors:  make(map[[2]int][]int),
+	}
+
+	open := func(x, y int) bool {
+		return !w.tileDefs.Get(w.tiles.tile(x, y)).Solid && !w.isDoor(x, y)
+	}
+
+	// Go block by block, which keeps the chunks of a chunked map in the cache.
+	var doors [][2]int
+	n := 0
+	const s = zoneBlockSize
+	for bx := 0; bx*s < rows; bx++ {
+		for by := 0; by*s < cols; by++ {
+			for x := bx * s; x < rows && x < (bx+1)*s; x++ {
+				for y := by * s; y < cols && y < (by+1)*s; y++ {
+					if w.isDoor(x, y) {
+						doors = append(doors, [2]int{x, y})
+					}
+					if z.at(x, y) >= 0 || !open(x, y) {
+						continue
+					}
+
+					stack := [][2]int{{x, y}}
+					for len(stack) > 0 {
+						p := stack[len(stack)-1]
+						stack = stack[:len(stack)-1]
+
+						i, j := p[0], p[1]
+						if !z.inside(i, j) || z.at(i, j) >= 0 || !open(i, j) {
+							continue
+						}
+
+						z.set(i, j, n)
+						stack = append(stack, [2]int{i + 1, j}, [2]int{i - 1, j}, [2]int{i, j + 1}, [2]int{i, j - 1})
+					}
+					n++
+				}
+			}
+		}
+	}
+
+	for _, pos := range doors {
+		var sides []int
+		for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			if zone := z.at(pos[0]+d[0], pos[1]+d[1]); zone >= 0 && !containsInt(sides, zone) {
+				sides = append(sides, zone)
+			}
+		}
+		z.doors[pos] = sides
+	}
+
+	z.group = make([]int, n)
+	z.link(w.openDoors)
+	return z
+}
+
+func containsInt(s []int, v int) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (z *zones) inside(x, y int) bool {
+	return x >= 0 && x < z.rows && y >= 0 && y < z.cols
+}
+
+func (z *zones) at(x, y int) int {
+	if !z.inside(x, y) {
+		return -1
+	}
+	b, ok := z.blocks[[2]int{x / zoneBlockSize, y / zoneBlockSize}]
+	if !ok {
+		return -1
+	}
+	return int(b[x%zoneBlockSize*zoneBlockSize+y%zoneBlockSize])
+}
+
+func (z *zones) set(x, y, zone int) {
+	pos := [2]int{x / zoneBlockSize, y / zoneBlockSize}
+	b, ok := z.blocks[pos]
+	if !ok {
+		b = make([]int32, zoneBlockSize*zoneBlockSize)
+		for i := range b {
+			b[i] = -1
+		}
+		z.blocks[pos] = b
+	}
+	b[x%zoneBlockSize*zoneBlockSize+y%zoneBlockSize] = int32(zone)
+}
+
+// link groups the zones that are connected through the open doors.
+func (z *zones) link(openDoors map[[2]int]bool) {
+	adj := make([][]int, len(z.group))
+	for pos, sides := range z.doors {
+		if !openDoors[pos] {
+			continue
+		}
+		for _, a := range sides {
+			for _, b := range sides {
+				if a != b {
+					adj[a] = append(adj[a], b)
+				}
+			}
+		}
+	}
+
+	for i := range z.group {
+		z.group[i] = -1
+	}
+	for i := range z.group {
+		if z.group[i] >= 0 {
+			continue
+		}
+
+		queue := []int{i}
+		z.group[i] = i
+		for len(queue) > 0 {
+			a := queue[0]
+			queue = queue[1:]
+			for _, b := range adj[a] {
+				if z.group[b] < 0 {
+					z.group[b] = i
+					queue = append(queue, b)
+				}
+			}
+		}
+	}
+}
+
+// zoneOf returns the zone at tile x, y. A door belongs to the zone on one of its sides.
+func (z *zones) zoneOf(x, y int) int {
+	if zone := z.at(x, y); zone >= 0 {
+		return zone
+	}
+	if sides := z.doors[[2]int{x, y}]; len(sides) > 0 {
+		return sides[0]
+	}
+	return -1
+}
+
+func (w *World) isDoor(x, y int) bool {
+	return w.tileDefs.Get(w.tiles.tile(x, y)).Triggers&TriggerDoor != 0
+}
+
+func (w *World) zones() *zones {
+	if w.zoneCache == nil {
+		w.zoneCache = computeZones(w)
+	}
+	return w.zoneCache
+}
+
+// NumZones returns the number of zones in the map. Zones are areas of open tiles
+// separated by walls and doors.
+func (w *World) NumZones() int {
+	return len(w.zones().group)
+}
+
+// Zone returns the zone of the tile at x, y, or -1 if it is a wall. Doors belong to
+// the zone on one of their sides.
+func (w *World) Zone(x, y int) int {
+	return w.zones().zoneOf(x, y)
+}
+
+// ZonesConnected reports if zones a and b are joined by open doors.
+func (w *World) ZonesConnected(a, b int) bool {
+	z := w.zones()
+	if a < 0 || b < 0 || a >= len(z.group) || b >= len(z.group) {
+		return false
+	}
+	return z.group[a] == z.group[b]
+}
+
+// Connected reports if sound can travel between p and q, that is if they are in
+// zones joined by open doors.
+func (w *World) Connected(p, q vec2.T) bool {
+	return w.ZonesConnected(
+		w.Zone(int(math.Floor(p[0])), int(math.Floor(p[1]))),
+		w.Zone(int(math.Floor(q[0])), int(math.Floor(q[1]))),
+	)
+}
+
+// DoorOpen reports if the door at x, y is open.
+func (w *World) DoorOpen(x, y int) bool {
+	return w.openDoors[[2]int{x, y}]
+}
+
+// OpenDoors returns the positions of the open doors, in no particular order.
+func (w *World) OpenDoors() [][2]int {
+	doors := make([][2]int, 0, len(w.openDoors))
+	for pos := range w.openDoors {
+		doors = append(doors, pos)
+	}
+	return doors
+}
+
+// OpenDoor opens the door at x, y, so it can be walked and seen through and joins the
+// zones on its sides. It returns false if there is no door at x, y.
+func (w *World) OpenDoor(x, y int) bool {
+	return w.setDoor(x, y, true)
+}
+
+// CloseDoor closes the door at x, y. It returns false if there is no door at x, y.
+func (w *World) CloseDoor(x, y int) bool {
+	return w.setDoor(x, y, false)
+}
+
+func (w *World) setDoor(x, y int, open bool) bool {
+	if !w.inside(x, y) || !w.isDoor(x, y) {
+		return false
+	}
+
+	pos := [2]int{x, y}
+	if w.openDoors[pos] == open {
+		return true
+	}
+	old := w.GetTile(x, y)
+
+	if open {
+		if w.openDoors == nil {
+			w.openDoors = make(map[[2]int]bool)
+		}
+		w.openDoors[pos] = true
+	} else {
+		delete(w.openDoors, pos)
+	}
+
+	if w.zoneCache != nil {
+		w.zoneCache.link(w.openDoors)
+	}
+
+	w.notify(TileChange{x, y, old, w.GetTile(x, y)})
+	return true
+}
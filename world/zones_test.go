@@ -0,0 +1,118 @@
+/*
+Copyright (C) 2017 Andreas T Jonsson
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package world_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/andreas-jonsson/go-wolf/world"
+	"github.com/ungerik/go3d/float64/vec2"
+)
+
+// zoneWorld has a room on each side of a door at 2,3.
+func zoneWorld(t *testing.T) *world.World {
+	old := world.Assets()
+	world.SetAssets(fstest.MapFS{
+		"textures/textures.json": {Data: []byte("[]")},
+		"textures/tiles.json":    {Data: []byte(`{"9": {"Triggers": ["door"]}}`)},
+	})
+	defer world.SetAssets(old)
+
+	w, err := world.NewWorldFromLevel(&world.Level{
+		Tiles: [][]int{
+			{1, 1, 1, 1, 1, 1, 1},
+			{1, 0, 0, 1, 0, 0, 1},
+			{1, 0, 0, 9, 0, 0, 1},
+			{1, 0, 0, 1, 0, 0, 1},
+			{1, 1, 1, 1, 1, 1, 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+var (
+	leftRoom  = vec2.T{1.5, 1.5}
+	rightRoom = vec2.T{3.5, 5.5}
+	doorway   = vec2.T{2.5, 3.5}
+)
+
+func TestZones(t *testing.T) {
+	w := zoneWorld(t)
+
+	if n := w.NumZones(); n != 2 {
+		t.Fatalf("got %d zones, want 2", n)
+	}
+	if w.Zone(0, 0) != -1 {
+		t.Error("a wall has a zone")
+	}
+	if z := w.Zone(2, 3); z != w.Zone(1, 1) && z != w.Zone(1, 5) {
+		t.Errorf("the door is in zone %d, not in one of the rooms", z)
+	}
+
+	check := func(when string, want bool) {
+		t.Helper()
+		if got := w.Connected(leftRoom, rightRoom); got != want {
+			t.Errorf("%s: rooms connected is %v, want %v", when, got, want)
+		}
+		if got := w.Connected(rightRoom, leftRoom); got != want {
+			t.Errorf("%s: connected is not symmetric", when)
+		}
+		if !w.Connected(leftRoom, vec2.T{3.5, 2.5}) || !w.Connected(doorway, doorway) {
+			t.Errorf("%s: a zone is not connected to itself", when)
+		}
+	}
+
+	check("closed", false)
+
+	if !w.OpenDoor(2, 3) || !w.DoorOpen(2, 3) {
+		t.Fatal("door did not open")
+	}
+	if doors := w.OpenDoors(); len(doors) != 1 || doors[0] != [2]int{2, 3} {
+		t.Errorf("open doors are %v", doors)
+	}
+	check("open", true)
+
+	if !w.CloseDoor(2, 3) || w.DoorOpen(2, 3) {
+		t.Fatal("door did not close")
+	}
+	if doors := w.OpenDoors(); len(doors) != 0 {
+		t.Errorf("open doors are %v", doors)
+	}
+	check("closed again", false)
+
+	if w.OpenDoor(1, 3) || w.OpenDoor(-1, 3) {
+		t.Error("opened a door in a wall")
+	}
+}
+
+func TestZonesWithDoorOpenedFirst(t *testing.T) {
+	// The zones are computed on first use, after the door was opened.
+	w := zoneWorld(t)
+	w.OpenDoor(2, 3)
+
+	if !w.Connected(leftRoom, rightRoom) {
+		t.Error("rooms are not connected through the open door")
+	}
+	if w.GetTile(2, 3) != 0 {
+		t.Errorf("open door reads as tile %d", w.GetTile(2, 3))
+	}
+}